@@ -0,0 +1,245 @@
+package slackauth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+const (
+	artifactsTimeout = 30 * time.Second       // time to save all artifacts
+	consoleWait      = 200 * time.Millisecond // time to wait for the console entries of the unwatched page
+)
+
+// ErrArtifacts is returned when the login fails and the failure artifacts
+// have been saved, see [WithArtifactsDir].  It wraps the original error.
+type ErrArtifacts struct {
+	Err   error
+	Dir   string   // directory with the artifacts of this failure
+	Files []string // paths of the saved files
+}
+
+func (e ErrArtifacts) Error() string {
+	return fmt.Sprintf("%v (failure artifacts saved to %s)", e.Err, e.Dir)
+}
+
+func (e ErrArtifacts) Unwrap() error {
+	return e.Err
+}
+
+// saveArtifacts saves the failure artifacts of the browser pages, if the
+// artifacts directory is set and err is not nil.  It returns err wrapped in
+// [ErrArtifacts], or err, if nothing was saved.
+func (c *Client) saveArtifacts(ctx context.Context, browser *rod.Browser, err error) error {
+	if err == nil || c.opts.artifactsDir == "" || browser == nil {
+		return err
+	}
+	// the context may be the reason of the failure.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), artifactsTimeout)
+	defer cancel()
+
+	dir := filepath.Join(c.opts.artifactsDir, "slackauth-"+time.Now().Format("20060102-150405.000"))
+	if merr := os.MkdirAll(dir, 0o700); merr != nil {
+		c.opts.lg.Error("failed to create artifacts directory", "dir", dir, "err", merr)
+		return err
+	}
+	pages, perr := browser.Context(ctx).Pages()
+	if perr != nil {
+		c.opts.lg.Error("failed to list pages for the failure artifacts", "err", perr)
+		return err
+	}
+	var (
+		files []string
+		index strings.Builder
+	)
+	for i, page := range pages {
+		info, ierr := page.Info()
+		if ierr != nil || !c.capturable(info.URL) {
+			continue
+		}
+		fmt.Fprintf(&index, "%d\t%s\t%s\t%s\n", i, info.TargetID, info.URL, info.Title)
+		v, _ := c.consoles.Load(info.TargetID)
+		cl, _ := v.(*consoleLog)
+		files = append(files, savePage(page.Context(ctx), dir, i, cl, c.opts.lg, c.secrets)...)
+	}
+	if index.Len() > 0 {
+		name := filepath.Join(dir, "targets.txt")
+		if werr := os.WriteFile(name, []byte(c.secrets.redact(index.String())), 0o600); werr == nil {
+			files = append(files, name)
+		}
+	}
+	if len(files) == 0 {
+		return err
+	}
+	c.opts.lg.Info("saved failure artifacts", "dir", dir, "files", len(files))
+	return ErrArtifacts{Err: err, Dir: dir, Files: files}
+}
+
+// capturable returns true if the page at pageURL may be captured.  In the
+// user browser, only the workspace pages are captured, as the other tabs
+// are none of our business.
+func (c *Client) capturable(pageURL string) bool {
+	if !c.opts.isUserBrowser() {
+		return true
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+	domain := c.wspDomain()
+	host := u.Hostname()
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// savePage saves the screenshot, the HTML and the console entries of the
+// page i to dir, and returns the paths of the saved files.  The console
+// entries are taken from cl, if the page was watched, see [Client.watchConsole].
+// The secrets are redacted from the HTML and the console entries.
+func savePage(page *rod.Page, dir string, i int, cl *consoleLog, lg *slog.Logger, sec *secrets) []string {
+	var files []string
+	save := func(kind, ext string, data []byte, err error) {
+		if err != nil {
+			lg.Warn("failed to capture the page", "page", i, "kind", kind, "err", err)
+			return
+		}
+		name := filepath.Join(dir, fmt.Sprintf("%d-%s.%s", i, kind, ext))
+		if err := os.WriteFile(name, data, 0o600); err != nil {
+			lg.Warn("failed to save the artifact", "file", name, "err", err)
+			return
+		}
+		files = append(files, name)
+	}
+
+	img, err := page.Screenshot(true, &proto.PageCaptureScreenshot{Format: proto.PageCaptureScreenshotFormatPng})
+	save("screenshot", "png", img, err)
+	html, err := page.HTML()
+	save("page", "html", []byte(sec.redact(html)), err)
+	var entries []string
+	if cl != nil {
+		entries = cl.lines()
+	} else {
+		entries, err = consoleEntries(page)
+	}
+	save("console", "log", []byte(sec.redact(strings.Join(entries, "\n"))), err)
+	return files
+}
+
+// consoleLog collects the console messages and the browser log entries of
+// the page.
+type consoleLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+// watch starts collecting the entries of the page, until the page context
+// is done.
+func (l *consoleLog) watch(page *rod.Page) (wait func(), err error) {
+	wait = page.EachEvent(l.consoleAPICalled, l.logEntryAdded)
+	if err := (proto.RuntimeEnable{}).Call(page); err != nil {
+		return nil, err
+	}
+	if err := (proto.LogEnable{}).Call(page); err != nil {
+		return nil, err
+	}
+	return wait, nil
+}
+
+func (l *consoleLog) consoleAPICalled(e *proto.RuntimeConsoleAPICalled) {
+	l.add(msTime(e.Timestamp), string(e.Type), consoleArgs(e.Args))
+}
+
+func (l *consoleLog) logEntryAdded(e *proto.LogEntryAdded) {
+	text := e.Entry.Text
+	if e.Entry.URL != "" {
+		text += " (" + e.Entry.URL + ")"
+	}
+	l.add(msTime(e.Entry.Timestamp), string(e.Entry.Level), "["+string(e.Entry.Source)+"] "+text)
+}
+
+func (l *consoleLog) add(ts time.Time, level, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, fmt.Sprintf("%s %-7s %s", ts.UTC().Format(time.RFC3339Nano), level, text))
+}
+
+// lines returns the entries collected so far.
+func (l *consoleLog) lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.entries)
+}
+
+// watchConsole starts collecting the console entries of the page for the
+// failure artifacts, if [WithArtifactsDir] is set.  The browser does not
+// report the console messages, that were logged before the Runtime domain
+// was enabled, so it must be called before the page is navigated.
+func (c *Client) watchConsole(page *rod.Page) error {
+	if c.opts.artifactsDir == "" {
+		return nil
+	}
+	cl := new(consoleLog)
+	wait, err := cl.watch(page)
+	if err != nil {
+		return err
+	}
+	go wait()
+	c.consoles.Store(page.TargetID, cl)
+	return nil
+}
+
+// consoleEntries returns the console messages and the browser log entries of
+// the page, that was not watched.  Only the browser log entries, and the
+// messages logged while waiting, are reported.
+func consoleEntries(page *rod.Page) ([]string, error) {
+	ctx, cancel := context.WithCancel(page.GetContext())
+	defer cancel()
+
+	cl := new(consoleLog)
+	wait, err := cl.watch(page.Context(ctx))
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wait()
+	}()
+	time.Sleep(consoleWait)
+	cancel()
+	<-done
+	return cl.lines(), nil
+}
+
+// msTime converts the runtime timestamp in milliseconds since the epoch to
+// time.
+func msTime(ts proto.RuntimeTimestamp) time.Time {
+	return time.Unix(0, int64(float64(ts)*float64(time.Millisecond)))
+}
+
+// consoleArgs formats the console call arguments.
+func consoleArgs(args []*proto.RuntimeRemoteObject) string {
+	ss := make([]string, 0, len(args))
+	for _, a := range args {
+		switch {
+		case a.Type == proto.RuntimeRemoteObjectTypeString:
+			ss = append(ss, a.Value.Str())
+		case a.Description != "":
+			ss = append(ss, a.Description)
+		case !a.Value.Nil():
+			ss = append(ss, a.Value.JSON("", ""))
+		default:
+			ss = append(ss, string(a.Type))
+		}
+	}
+	return strings.Join(ss, " ")
+}
@@ -0,0 +1,111 @@
+package slackauth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ysmood/gson"
+
+	"github.com/rusq/slackauth/slackauthtest"
+)
+
+func TestErrArtifacts(t *testing.T) {
+	err := ErrArtifacts{Err: ErrInvalidCredentials, Dir: "/tmp/slackauth-1"}
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.Equal(t, "invalid credentials (failure artifacts saved to /tmp/slackauth-1)", err.Error())
+}
+
+func TestClient_saveArtifacts_noop(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name string
+		dir  string
+		err  error
+	}{
+		{"no error", t.TempDir(), nil},
+		{"no directory", "", errFailed},
+		{"no browser", t.TempDir(), errFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{opts: options{artifactsDir: tt.dir, lg: discardLogger()}}
+			err := c.saveArtifacts(context.Background(), nil, tt.err)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestClient_capturable(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    options
+		pageURL string
+		want    bool
+	}{
+		{"incognito", options{}, "https://example.com/", true},
+		{"user browser, workspace", options{forceUser: true}, "https://acme.slack.com/client", true},
+		{"user browser, slack", options{forceUser: true}, "https://app.slack.com/client", true},
+		{"user browser, other", options{forceUser: true}, "https://mail.example.com/", false},
+		{"attached, other", options{remotePort: 9222}, "https://bank.example.com/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{wspURL: "https://acme.slack.com/", opts: tt.opts}
+			assert.Equal(t, tt.want, c.capturable(tt.pageURL))
+		})
+	}
+}
+
+func Test_consoleArgs(t *testing.T) {
+	args := []*proto.RuntimeRemoteObject{
+		{Type: proto.RuntimeRemoteObjectTypeString, Value: gson.New("sign in failed:")},
+		{Type: proto.RuntimeRemoteObjectTypeNumber, Value: gson.New(42), Description: "42"},
+		{Type: proto.RuntimeRemoteObjectTypeObject, Description: "Error: boom"},
+		{Type: proto.RuntimeRemoteObjectTypeUndefined},
+	}
+	assert.Equal(t, "sign in failed: 42 Error: boom undefined", consoleArgs(args))
+}
+
+func Test_msTime(t *testing.T) {
+	want := time.Date(2024, 10, 18, 12, 0, 0, 500*int(time.Millisecond), time.UTC)
+	got := msTime(proto.RuntimeTimestamp(want.UnixMilli()))
+	assert.True(t, want.Equal(got), "got %s", got)
+}
+
+func TestE2E_artifacts(t *testing.T) {
+	srv := slackauthtest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	c := e2eClient(t, srv, WithArtifactsDir(dir))
+
+	_, _, err := c.Headless(e2eContext(t), slackauthtest.DefaultEmail, "wrong")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	var aerr ErrArtifacts
+	require.ErrorAs(t, err, &aerr)
+	assert.Equal(t, dir, filepath.Dir(aerr.Dir))
+
+	var names []string
+	for _, f := range aerr.Files {
+		assert.FileExists(t, f)
+		names = append(names, filepath.Base(f))
+	}
+	assert.Contains(t, names, "targets.txt")
+	assert.Contains(t, names, "0-screenshot.png")
+	assert.Contains(t, names, "0-page.html")
+	assert.Contains(t, names, "0-console.log")
+
+	html, err := os.ReadFile(filepath.Join(aerr.Dir, "0-page.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(html), "password_error")
+	console, err := os.ReadFile(filepath.Join(aerr.Dir, "0-console.log"))
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(console), "sign in failed:"), "console log: %s", console)
+}
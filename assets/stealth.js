@@ -0,0 +1,95 @@
+// Evasions for the headless browser detection.  This script is evaluated on
+// every new document before any of the page scripts run.
+(() => {
+  const patch = (obj, prop, value) => {
+    try {
+      Object.defineProperty(obj, prop, { get: () => value, configurable: true });
+    } catch (e) {}
+  };
+
+  // navigator.webdriver is true in automated browsers.
+  patch(Navigator.prototype, 'webdriver', undefined);
+
+  // headless browsers have no plugins and mime types.
+  if (navigator.plugins.length === 0) {
+    const mkArray = (items, proto) => {
+      const arr = Object.create(proto);
+      items.forEach((item, i) => {
+        arr[i] = item;
+        arr[item.name || item.type] = item;
+      });
+      patch(arr, 'length', items.length);
+      arr.item = (i) => arr[i] || null;
+      arr.namedItem = (name) => arr[name] || null;
+      arr.refresh = () => {};
+      return arr;
+    };
+    const pdf = { type: 'application/pdf', suffixes: 'pdf', description: 'Portable Document Format' };
+    const names = ['PDF Viewer', 'Chrome PDF Viewer', 'Chromium PDF Viewer', 'Microsoft Edge PDF Viewer', 'WebKit built-in PDF'];
+    const plugins = names.map((name) => ({ name, filename: 'internal-pdf-viewer', description: 'Portable Document Format', length: 1, 0: pdf }));
+    patch(Navigator.prototype, 'plugins', mkArray(plugins, PluginArray.prototype));
+    patch(Navigator.prototype, 'mimeTypes', mkArray([pdf], MimeTypeArray.prototype));
+  }
+
+  // headless browsers may report empty languages.
+  if (!navigator.languages || navigator.languages.length === 0) {
+    patch(Navigator.prototype, 'languages', [navigator.language || 'en-US', 'en']);
+  }
+
+  // window.chrome is missing in headless mode.
+  if (!window.chrome) {
+    window.chrome = {};
+  }
+  if (!window.chrome.runtime) {
+    window.chrome.runtime = {
+      OnInstalledReason: {},
+      OnRestartRequiredReason: {},
+      PlatformArch: {},
+      PlatformOs: {},
+      RequestUpdateCheckStatus: {},
+      connect: () => {},
+      sendMessage: () => {},
+    };
+  }
+
+  // notifications permission is "denied" in headless mode, while
+  // Notification.permission is "default".
+  if (navigator.permissions && navigator.permissions.query) {
+    const query = navigator.permissions.query.bind(navigator.permissions);
+    navigator.permissions.query = (params) =>
+      params && params.name === 'notifications'
+        ? Promise.resolve({ state: Notification.permission, onchange: null })
+        : query(params);
+  }
+
+  // headless browsers report SwiftShader or "Google Inc." as the WebGL
+  // vendor and renderer.
+  const UNMASKED_VENDOR = 0x9245;
+  const UNMASKED_RENDERER = 0x9246;
+  for (const ctx of [window.WebGLRenderingContext, window.WebGL2RenderingContext]) {
+    if (!ctx) {
+      continue;
+    }
+    const getParameter = ctx.prototype.getParameter;
+    ctx.prototype.getParameter = function (p) {
+      if (p === UNMASKED_VENDOR) {
+        return 'Intel Inc.';
+      }
+      if (p === UNMASKED_RENDERER) {
+        return 'Intel Iris OpenGL Engine';
+      }
+      return getParameter.call(this, p);
+    };
+  }
+
+  // outer dimensions are zero in headless mode.
+  if (window.outerWidth === 0 || window.outerHeight === 0) {
+    patch(window, 'outerWidth', window.innerWidth);
+    patch(window, 'outerHeight', window.innerHeight + 85);
+  }
+
+  // hardwareConcurrency can be 1 in containers.
+  if (navigator.hardwareConcurrency < 2) {
+    patch(Navigator.prototype, 'hardwareConcurrency', 4);
+  }
+})();
@@ -1,26 +1,122 @@
 package slackauth
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/defaults"
 	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/launcher/flags"
 	"github.com/go-rod/rod/lib/proto"
 )
 
 // newBrwsrLauncher creates a new incognito browser launcher with the given
-// headless mode.
-func (c *Client) newBrwsrLauncher(headless bool) *launcher.Launcher {
-	l := launcher.New().Headless(headless).Leakless(isLeaklessEnabled).Devtools(false)
-	if binpath, ok := c.opts.browserPath(); ok {
-		l = l.Bin(binpath)
+// headless mode.  If there's no browser on the system, the bundled one is
+// used, and it is downloaded, if necessary.  If the persistent profile
+// directory is set, it is locked and used as the user data directory.
+func (c *Client) newBrwsrLauncher(ctx context.Context, headless bool) (*launcher.Launcher, error) {
+	binpath, ok := c.opts.browserPath()
+	if !ok {
+		if !c.opts.useBundledBrwsr {
+			c.opts.lg.Warn("no browsers found, using the bundled browser")
+		}
+		bundled := c.opts.bundled
+		if bundled.HTTPClient == nil {
+			bundled.HTTPClient = c.opts.httpClient()
+		}
+		var err error
+		if binpath, err = bundled.Get(ctx); err != nil {
+			return nil, fmt.Errorf("bundled browser: %w", err)
+		}
+		c.opts.lg.Info("using the bundled browser", "path", binpath)
 	}
-	return l
+	l := launcher.New().Bin(binpath).Headless(headless).Leakless(isLeaklessEnabled).Devtools(false)
+	if defaults.Dir == "" {
+		// the temporary user data directory, that the launcher created, is
+		// removed on cleanup, even if it is replaced below, by the browser
+		// flags or the launcher hook, as only this one is known to be ours.
+		tmp := l.Get(flags.UserDataDir)
+		c.atClose(func() error { return os.RemoveAll(tmp) })
+	}
+	if c.opts.stealth {
+		l = stealthFlags(l, headless)
+	}
+	if fp := c.opts.emulation(); fp.Width > 0 && fp.Height > 0 {
+		l = l.Set("window-size", fmt.Sprintf("%d,%d", fp.Width, fp.Height))
+	}
+	if c.opts.dataDir != "" {
+		if err := c.lockDataDir(); err != nil {
+			return nil, err
+		}
+		l = l.UserDataDir(c.opts.dataDir)
+	}
+	return c.customise(l), nil
+}
+
+// lockDataDir locks the persistent profile directory.  The lock is held by
+// the client until [Client.Close], so that the subsequent logins with the
+// same client reuse it.
+func (c *Client) lockDataDir() error {
+	if c.profileLocked {
+		return nil
+	}
+	unlock, err := lockProfile(c.opts.dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to lock profile directory %s: %w", c.opts.dataDir, err)
+	}
+	c.profileLocked = true
+	c.atClose(func() error {
+		c.profileLocked = false
+		return unlock()
+	})
+	return nil
+}
+
+// snapshotLauncher creates a new browser launcher that uses the snapshot of
+// the user's profile.  The snapshot is stored in the temporary directory,
+// which is removed on cleanup.
+func (c *Client) snapshotLauncher() (*launcher.Launcher, error) {
+	binpath, ok := c.opts.browserPath()
+	if !ok {
+		return nil, ErrNoBrowsers
+	}
+	src, err := userDataDir(LocalBrowser{Name: browserName(binpath), Path: binpath})
+	if err != nil {
+		return nil, err
+	}
+	dst, err := os.MkdirTemp("", "slackauth-profile-*")
+	if err != nil {
+		return nil, err
+	}
+	c.atClose(func() error { return os.RemoveAll(dst) })
+
+	c.opts.lg.Debug("creating profile snapshot", "src", src, "dst", dst)
+	if err := snapshotProfile(dst, src, c.opts.profileDir, func(path string, err error) {
+		c.opts.lg.Debug("skipping profile file", "path", path, "err", err)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create profile snapshot: %w", err)
+	}
+
+	l := launcher.NewUserMode().
+		Bin(binpath).
+		UserDataDir(dst).
+		ProfileDir(c.opts.profileDir).
+		RemoteDebuggingPort(0).
+		Headless(false).
+		Leakless(isLeaklessEnabled).
+		Devtools(false)
+	return c.customise(l), nil
 }
 
 // usrBrwsrLauncher creates a new user-mode browser launcher.
@@ -29,9 +125,146 @@ func (c *Client) usrBrwsrLauncher() *launcher.Launcher {
 	if binpath, ok := c.opts.browserPath(); ok {
 		l = l.Bin(binpath)
 	}
+	if c.opts.profileDir != "" {
+		l = l.ProfileDir(c.opts.profileDir)
+	}
+	return c.customise(l)
+}
+
+// euid returns the effective user ID, it is a variable for testing.
+var euid = os.Geteuid
+
+// isContainerRoot returns true if running as root on Linux, which is
+// usually the case in containers.  Chrome refuses to start as root with the
+// sandbox enabled.
+func isContainerRoot() bool {
+	return runtime.GOOS == "linux" && euid() == 0
+}
+
+// containerFlags sets the flags that are required to run the browser as
+// root in the container: the sandbox is disabled, and /dev/shm, that is
+// only 64MB in Docker by default, is not used.
+func containerFlags(l *launcher.Launcher) *launcher.Launcher {
+	return l.NoSandbox(true).Set("disable-dev-shm-usage")
+}
+
+// customise applies the container defaults, the locale and timezone, the
+// proxy, the root CAs, the custom browser flags and the launcher hook to the
+// launcher, in this order, so that the user settings take precedence.
+func (c *Client) customise(l *launcher.Launcher) *launcher.Launcher {
+	if isContainerRoot() {
+		l = containerFlags(l)
+	}
+	l = emulationFlags(l, c.opts.emulation())
+	l = proxyFlags(l, c.opts.proxy)
+	if c.opts.browserCAs {
+		l = caFlags(l, c.opts.caCerts)
+	}
+	for name, value := range c.opts.browserFlags {
+		if value == "" {
+			l = l.Set(flags.Flag(name))
+		} else {
+			l = l.Set(flags.Flag(name), value)
+		}
+	}
+	if c.opts.launcherHook != nil {
+		c.opts.launcherHook(l)
+	}
 	return l
 }
 
+// isAttached returns true if the client is configured to attach to the
+// running browser.
+func (o options) isAttached() bool {
+	return o.controlURL != "" || o.remotePort > 0
+}
+
+// isUserBrowser returns true if the browser is the user's browser, and may
+// contain cookies unrelated to Slack.
+func (o options) isUserBrowser() bool {
+	return o.forceUser || o.isAttached()
+}
+
+// controlURL returns the control URL of the browser.  If the client is
+// configured to attach to the running browser, it resolves the configured
+// address, otherwise it launches a new browser using the launcher returned by
+// newLauncher.  When replaying the recorded session, there's no browser, and
+// the URL is empty.
+func (c *Client) controlURL(ctx context.Context, newLauncher func() (*launcher.Launcher, error)) (string, error) {
+	if c.cdpPlay != nil {
+		// the recorded session needs no browser.
+		return "", nil
+	}
+	if c.opts.isAttached() {
+		addr := c.opts.controlURL
+		if addr == "" {
+			addr = "127.0.0.1:" + strconv.Itoa(c.opts.remotePort)
+		}
+		url, err := resolveControlURL(addr)
+		if err != nil {
+			return "", ErrBrowser{Err: err, FailedTo: "resolve control URL, is the browser running with remote debugging enabled?"}
+		}
+		c.opts.lg.Info("attaching to the running browser", "url", url)
+		return url, nil
+	}
+	l, err := newLauncher()
+	if err != nil {
+		return "", ErrBrowser{Err: err, FailedTo: "prepare launcher"}
+	}
+	url, err := l.Context(ctx).Launch()
+	if err != nil {
+		return "", ErrBrowser{Err: err, FailedTo: "launch, you may need to close your browser first"}
+	}
+	c.atClose(launcherCleanup(l))
+	return url, nil
+}
+
+// launcherCleanup returns the function that waits for the browser to exit.
+// It does not remove the user data directory, as it may be set by the
+// caller, the temporary one is removed by the cleanup registered in
+// newBrwsrLauncher.
+func launcherCleanup(l *launcher.Launcher) func() error {
+	return func() error {
+		// Cleanup removes the user data directory, if it is set.
+		l.UserDataDir("")
+		l.Cleanup()
+		return nil
+	}
+}
+
+// resolveControlURL resolves the address to the DevTools websocket URL.
+// Websocket URLs that have the path are returned as is, as they point to the
+// browser already, and remote services may not implement the /json/version
+// endpoint.
+func resolveControlURL(addr string) (string, error) {
+	if u, err := url.Parse(addr); err == nil && (u.Scheme == "ws" || u.Scheme == "wss") && strings.Trim(u.Path, "/") != "" {
+		return addr, nil
+	}
+	return launcher.ResolveURL(addr)
+}
+
+// browserContext returns the context for the browser connection.  When the
+// client is attached to the running browser, the connection context is
+// cancelled on [Client.Close], which drops the connection.
+func (c *Client) browserContext(ctx context.Context) context.Context {
+	if !c.opts.isAttached() {
+		return ctx
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c.atClose(toerrfn(cancel))
+	return ctx
+}
+
+// atCloseBrowser registers the browser cleanup function.  The browser that
+// the client attached to is not closed, only the pages that the client has
+// opened are closed.
+func (c *Client) atCloseBrowser(b *rod.Browser) {
+	if c.opts.isAttached() {
+		return
+	}
+	c.atClose(b.Close)
+}
+
 // browserPath returns the path to the browser executable and a boolean
 // indicating whether the path is valid.
 func (o options) browserPath() (path string, ok bool) {
@@ -51,7 +284,14 @@ func (o options) browserPath() (path string, ok bool) {
 var ErrNoBrowsers = fmt.Errorf("no browsers found")
 
 // ListBrowsers returns a list of browsers that are installed on the system.
+// The browser set in the SLACKAUTH_BROWSER environment variable is listed
+// first, followed by the browsers registered with [RegisterBrowser].  If
+// the environment variable points to a missing browser, [ErrEnvBrowser] is
+// returned.
 func ListBrowsers() ([]LocalBrowser, error) {
+	if err := checkEnvBrowser(); err != nil {
+		return nil, err
+	}
 	LocalBrowsers, ok := discover()
 	if !ok {
 		return nil, ErrNoBrowsers
@@ -60,33 +300,70 @@ func ListBrowsers() ([]LocalBrowser, error) {
 }
 
 const (
-	bChrome   = "Google Chrome"
-	bChromium = "Chromium"
-	bEdge     = "Microsoft Edge"
-	bBrave    = "Brave"
+	bChrome    = "Google Chrome"
+	bChromium  = "Chromium"
+	bEdge      = "Microsoft Edge"
+	bBrave     = "Brave"
+	bVivaldi   = "Vivaldi"
+	bOpera     = "Opera"
+	bUngoogled = "Ungoogled Chromium"
 )
 
 // LocalBrowser represents a browser that is installed on the system.
 type LocalBrowser struct {
+	Name    string
+	Path    string
+	Version string  // browser version, i.e. "130.0.6723.58", if known
+	Channel Channel // release channel or the packaging
+}
+
+// candidate is the browser executable that may be installed on the system.
+type candidate struct {
 	Name string
 	Path string
 }
 
 // discover returns the list of browsers that are installed on the system and a
-// boolean indicating whether any browsers were found.
+// boolean indicating whether any browsers were found.  Symlinks pointing to
+// the same executable are reported once.  The versions are queried in
+// parallel, as each query runs the browser.
 func discover() (found []LocalBrowser, has bool) {
-	for _, br := range browserList {
-		var err error
+	seen := make(map[string]bool)
+	var resolvedPaths []string
+	for _, br := range candidates() {
 		p, err := exec.LookPath(br.Path)
-		if err == nil {
-			found = append(found, LocalBrowser{br.Name, p})
+		if err != nil {
+			continue
+		}
+		resolved, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			resolved = p
+		}
+		if seen[resolved] {
+			continue
 		}
+		seen[resolved] = true
+		resolvedPaths = append(resolvedPaths, resolved)
+		found = append(found, LocalBrowser{
+			Name:    br.Name,
+			Path:    p,
+			Channel: detectChannel(p),
+		})
 	}
+	var wg sync.WaitGroup
+	for i := range found {
+		wg.Add(1)
+		go func(b *LocalBrowser, path string) {
+			defer wg.Done()
+			b.Version = browserVersion(path)
+		}(&found[i], resolvedPaths[i])
+	}
+	wg.Wait()
 
 	return found, len(found) > 0
 }
 
-var browserList = map[string][]LocalBrowser{
+var browserList = map[string][]candidate{
 	"darwin": {
 		{bBrave, "/Applications/Brave Browser.app/Contents/MacOS/Brave Browser"},
 		{bEdge, "/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge"},
@@ -97,6 +374,12 @@ var browserList = map[string][]LocalBrowser{
 		{bChrome, "/usr/bin/google-chrome"},
 		{bChromium, "/usr/bin/chromium"},
 		{bChromium, "/usr/bin/chromium-browser"},
+		{bChrome, "/Applications/Google Chrome Beta.app/Contents/MacOS/Google Chrome Beta"},
+		{bBrave, "/Applications/Brave Browser Beta.app/Contents/MacOS/Brave Browser Beta"},
+		{bBrave, "/Applications/Brave Browser Nightly.app/Contents/MacOS/Brave Browser Nightly"},
+		{bEdge, "/Applications/Microsoft Edge Beta.app/Contents/MacOS/Microsoft Edge Beta"},
+		{bVivaldi, "/Applications/Vivaldi.app/Contents/MacOS/Vivaldi"},
+		{bOpera, "/Applications/Opera.app/Contents/MacOS/Opera"},
 	},
 	"linux": {
 		{bBrave, "brave-browser"},
@@ -113,30 +396,51 @@ var browserList = map[string][]LocalBrowser{
 		{bChromium, "/usr/bin/chromium-browser"},
 		{bChromium, "/snap/bin/chromium"},
 		{bChromium, "/data/data/com.termux/files/usr/bin/chromium-browser"},
+		{bChrome, "google-chrome-beta"},
+		{bChrome, "google-chrome-unstable"},
+		{bBrave, "brave-browser-beta"},
+		{bBrave, "brave-browser-nightly"},
+		{bEdge, "microsoft-edge-beta"},
+		{bEdge, "microsoft-edge-dev"},
+		{bVivaldi, "vivaldi"},
+		{bVivaldi, "vivaldi-stable"},
+		{bOpera, "opera"},
+		{bUngoogled, "ungoogled-chromium"},
 		// flatpacks
 		{bBrave, "/var/lib/flatpak/exports/bin/com.brave.Browser"},
 		{bChromium, "/var/lib/flatpak/exports/bin/org.chromium.Chromium"},
+		{bChrome, "/var/lib/flatpak/exports/bin/com.google.Chrome"},
+		{bEdge, "/var/lib/flatpak/exports/bin/com.microsoft.Edge"},
+		{bVivaldi, "/var/lib/flatpak/exports/bin/com.vivaldi.Vivaldi"},
+		{bOpera, "/var/lib/flatpak/exports/bin/com.opera.Opera"},
+		{bUngoogled, "/var/lib/flatpak/exports/bin/io.github.ungoogled_software.ungoogled_chromium"},
 	},
 	"openbsd": {
 		{bChrome, "chrome"},
 		{bChromium, "chromium"},
 	},
 	"windows": append(
-		[]LocalBrowser{{bEdge, "edge"}, {bBrave, "brave"}, {bChrome, "chrome"}},
+		[]candidate{{bEdge, "edge"}, {bBrave, "brave"}, {bChrome, "chrome"}},
 		expandWindowsExePaths(
-			LocalBrowser{bEdge, `Microsoft\Edge\Application\msedge.exe`},
-			LocalBrowser{bBrave, `BraveSoftware\Brave-Browser\Application\brave.exe`},
-			LocalBrowser{bChrome, `Google\Chrome\Application\chrome.exe`},
-			LocalBrowser{bChromium, `Chromium\Application\chrome.exe`},
+			candidate{bEdge, `Microsoft\Edge\Application\msedge.exe`},
+			candidate{bBrave, `BraveSoftware\Brave-Browser\Application\brave.exe`},
+			candidate{bChrome, `Google\Chrome\Application\chrome.exe`},
+			candidate{bChromium, `Chromium\Application\chrome.exe`},
+			candidate{bChrome, `Google\Chrome Beta\Application\chrome.exe`},
+			candidate{bChrome, `Google\Chrome SxS\Application\chrome.exe`},
+			candidate{bEdge, `Microsoft\Edge Beta\Application\msedge.exe`},
+			candidate{bVivaldi, `Vivaldi\Application\vivaldi.exe`},
+			candidate{bOpera, `Programs\Opera\opera.exe`},
 		)...),
 }[runtime.GOOS]
 
 // lookPath is extended launcher.LookPath that includes support for Brave
-// browser.
+// browser.  The browser set in the SLACKAUTH_BROWSER environment variable
+// and the ones registered with [RegisterBrowser] take precedence.
 //
 // (c) MIT license: Copyright 2019 Yad Smood
 func lookPath() (found string, has bool) {
-	for _, b := range browserList {
+	for _, b := range candidates() {
 		var err error
 		found, err = exec.LookPath(b.Path)
 		has = err == nil
@@ -151,20 +455,50 @@ func lookPath() (found string, has bool) {
 // expandWindowsExePaths is based on the same function from rod's browser.go.
 //
 // (c) MIT license: Copyright 2019 Yad Smood
-func expandWindowsExePaths(list ...LocalBrowser) []LocalBrowser {
-	newList := []LocalBrowser{}
+func expandWindowsExePaths(list ...candidate) []candidate {
+	newList := []candidate{}
 	for _, p := range list {
 		newList = append(
 			newList,
-			LocalBrowser{p.Name, filepath.Join(os.Getenv("ProgramFiles"), p.Path)},
-			LocalBrowser{p.Name, filepath.Join(os.Getenv("ProgramFiles(x86)"), p.Path)},
-			LocalBrowser{p.Name, filepath.Join(os.Getenv("LocalAppData"), p.Path)},
+			candidate{p.Name, filepath.Join(os.Getenv("ProgramFiles"), p.Path)},
+			candidate{p.Name, filepath.Join(os.Getenv("ProgramFiles(x86)"), p.Path)},
+			candidate{p.Name, filepath.Join(os.Getenv("LocalAppData"), p.Path)},
 		)
 	}
 
 	return newList
 }
 
+// presetCookies sets the cookies from the options, see [WithCookie], in the
+// browser.  The browser that the client attached to is the user's own, and
+// the cookies would persist in the user's profile, so they are not set.
+func (c *Client) presetCookies(b *rod.Browser) error {
+	if c.opts.isAttached() {
+		if len(c.opts.cookies) > 0 {
+			c.opts.lg.Warn("cookies are not set in the attached browser", "count", len(c.opts.cookies))
+		}
+		return nil
+	}
+	return setCookies(b, c.opts.cookies)
+}
+
+// browserCookies returns the cookies of the browser.  The user's browser
+// may have cookies unrelated to Slack, we need not store them, so only the
+// cookies of Slack, the identity providers and the workspace domain are
+// returned.
+func (c *Client) browserCookies(ctx context.Context, b *rod.Browser) ([]*http.Cookie, error) {
+	cookies, err := convertCookies(b.GetCookies())
+	if err != nil {
+		return nil, ErrBrowser{Err: err, FailedTo: "extract cookies"}
+	}
+	if c.opts.isUserBrowser() {
+		trace.WithRegion(ctx, "filterCookies", func() {
+			cookies = filterCookies(cookies, c.wspDomain())
+		})
+	}
+	return cookies, nil
+}
+
 func setCookies(browser *rod.Browser, cookies []*http.Cookie) error {
 	if len(cookies) == 0 {
 		return nil
@@ -179,7 +513,9 @@ func setCookies(browser *rod.Browser, cookies []*http.Cookie) error {
 	return nil
 }
 
-// RemveBundled removes the bundled browser from the system.
+// RemoveBrowser removes all revisions of the bundled browser from the
+// default cache directory.  Use [BundledBrowser.Remove] to remove a single
+// revision.
 func RemoveBrowser() error {
 	bpath := launcher.DefaultBrowserDir
 	if bpath == "" {
@@ -1,11 +1,21 @@
 package slackauth
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/launcher/flags"
 )
 
 func Test_options_browserPath(t *testing.T) {
@@ -27,7 +37,7 @@ func Test_options_browserPath(t *testing.T) {
 		localBrowser    string
 		codeFn          func(email string) (code int, err error)
 		debug           bool
-		lg              Logger
+		lg              *slog.Logger
 	}
 	tests := []struct {
 		name     string
@@ -110,3 +120,285 @@ func TestRemoveBrowser(t *testing.T) {
 		})
 	}
 }
+
+func Test_resolveControlURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/json/version" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"webSocketDebuggerUrl":"ws://localhost:9222/devtools/browser/abc"}`))
+	}))
+	defer srv.Close()
+	srvHost := strings.TrimPrefix(srv.URL, "http://")
+
+	tests := []struct {
+		name    string
+		addr    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "websocket url with path is returned as is",
+			addr: "wss://chrome.example.com/devtools/browser/xyz?token=123",
+			want: "wss://chrome.example.com/devtools/browser/xyz?token=123",
+		},
+		{
+			name: "http endpoint is resolved",
+			addr: srv.URL,
+			want: "ws://" + srvHost + "/devtools/browser/abc",
+		},
+		{
+			name: "host:port is resolved",
+			addr: srvHost,
+			want: "ws://" + srvHost + "/devtools/browser/abc",
+		},
+		{
+			name:    "nothing listening",
+			addr:    "127.0.0.1:1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveControlURL(tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveControlURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveControlURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_customise(t *testing.T) {
+	tests := []struct {
+		name      string
+		root      bool
+		opts      []Option
+		wantFlags map[flags.Flag][]string
+		noFlags   []flags.Flag
+	}{
+		{
+			name:    "defaults",
+			noFlags: []flags.Flag{"disable-dev-shm-usage", "proxy-server"},
+		},
+		{
+			name: "root gets container defaults",
+			root: true,
+			wantFlags: map[flags.Flag][]string{
+				flags.NoSandbox:         nil,
+				"disable-dev-shm-usage": nil,
+			},
+		},
+		{
+			name: "browser flags are normalised",
+			opts: []Option{WithBrowserFlags(map[string]string{
+				"--proxy-server":        "http://proxy:3128",
+				"--lang=de-DE":          "",
+				"disable-dev-shm-usage": "",
+			})},
+			wantFlags: map[flags.Flag][]string{
+				"proxy-server":          {"http://proxy:3128"},
+				"lang":                  {"de-DE"},
+				"disable-dev-shm-usage": nil,
+			},
+		},
+		{
+			name: "locale in user mode",
+			opts: []Option{WithLocale("de-DE")},
+			wantFlags: map[flags.Flag][]string{
+				"lang":        {"de-DE"},
+				"accept-lang": {"de-DE,de;q=0.9,en;q=0.8"},
+			},
+		},
+		{
+			name: "browser flags override the locale",
+			opts: []Option{WithLocale("de-DE"), WithBrowserFlags(map[string]string{"lang": "fr-FR"})},
+			wantFlags: map[flags.Flag][]string{
+				"lang": {"fr-FR"},
+			},
+		},
+		{
+			name: "hook runs last",
+			opts: []Option{
+				WithBrowserFlags(map[string]string{"window-size": "800,600"}),
+				WithLauncherHook(func(l *launcher.Launcher) {
+					l.Set("window-size", "1024,768").Delete("no-startup-window")
+				}),
+			},
+			wantFlags: map[flags.Flag][]string{"window-size": {"1024,768"}},
+			noFlags:   []flags.Flag{"no-startup-window"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldEUID := euid
+			t.Cleanup(func() { euid = oldEUID })
+			euid = func() int {
+				if tt.root {
+					return 0
+				}
+				return 1000
+			}
+			if tt.root && runtime.GOOS != "linux" {
+				t.Skip("container defaults are linux only")
+			}
+
+			c := &Client{}
+			c.opts.apply(tt.opts)
+			l := c.customise(launcher.NewUserMode())
+			for name, want := range tt.wantFlags {
+				got, ok := l.GetFlags(name)
+				if !ok {
+					t.Errorf("flag %q is not set", name)
+					continue
+				}
+				if len(got) != len(want) || (len(want) > 0 && got[0] != want[0]) {
+					t.Errorf("flag %q = %v, want %v", name, got, want)
+				}
+			}
+			for _, name := range tt.noFlags {
+				if _, ok := l.GetFlags(name); ok {
+					t.Errorf("flag %q must not be set", name)
+				}
+			}
+		})
+	}
+}
+
+// cookieBrowser returns the browser connected to the fake CDP client, that
+// has the cookies of Slack and of an unrelated site.
+func cookieBrowser(t *testing.T) (*rod.Browser, *fakeBrowser) {
+	t.Helper()
+	fake := newFakeBrowser()
+	fake.results["Storage.getCookies"] = `{"cookies":[` +
+		`{"name":"d","value":"xoxd-1","domain":".slack.com","path":"/"},` +
+		`{"name":"session","value":"bank","domain":"bank.example.com","path":"/"}]}`
+	fake.results["Storage.setCookies"] = `{}`
+	b := rod.New().Client(fake)
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return b, fake
+}
+
+func TestClient_browserCookies(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  options
+		names []string
+	}{
+		{"launched", options{}, []string{"d", "session"}},
+		{"attached", options{remotePort: 9222}, []string{"d"}},
+		{"control URL", options{controlURL: "ws://127.0.0.1:9222/devtools/browser/x"}, []string{"d"}},
+		{"user mode", options{forceUser: true}, []string{"d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := cookieBrowser(t)
+			c := &Client{wspURL: "https://acme.slack.com/", opts: tt.opts}
+			cookies, err := c.browserCookies(context.Background(), b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, ck := range cookies {
+				names = append(names, ck.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.names, ",") {
+				t.Errorf("cookies = %v, want %v", names, tt.names)
+			}
+		})
+	}
+}
+
+func TestClient_presetCookies(t *testing.T) {
+	cookies := []*http.Cookie{{Name: "d", Value: "xoxd-1", Domain: ".slack.com", Path: "/"}}
+	tests := []struct {
+		name string
+		opts options
+		want bool // cookies set in the browser
+	}{
+		{"launched", options{cookies: cookies}, true},
+		{"attached", options{cookies: cookies, remotePort: 9222}, false},
+		{"no cookies", options{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := cookieBrowser(t)
+			tt.opts.lg = discardLogger()
+			c := &Client{opts: tt.opts}
+			if err := c.presetCookies(b); err != nil {
+				t.Fatal(err)
+			}
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			if got := slices.Contains(fake.calls, "Storage.setCookies"); got != tt.want {
+				t.Errorf("cookies set = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// launchableBrowser returns the fake browser executable, that the launcher
+// can start: it prints the DevTools URL, as the real one does, and exits.
+// The connection to the URL fails.
+func launchableBrowser(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake browser is a shell script")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"webSocketDebuggerUrl":"ws://` + r.Host + `/devtools/browser/x"}`))
+	}))
+	t.Cleanup(srv.Close)
+	bin := filepath.Join(t.TempDir(), "chromium")
+	script := "#!/bin/sh\necho 'DevTools listening on ws://" + srv.Listener.Addr().String() + "/devtools/browser/x' >&2\n"
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+func TestClient_launcherCleanup(t *testing.T) {
+	bin := launchableBrowser(t)
+	t.Run("keeps the directory set by the hook", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "Local State"), []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		c := &Client{opts: options{localBrowser: bin, lg: discardLogger(), launcherHook: func(l *launcher.Launcher) {
+			l.UserDataDir(dir)
+		}}}
+		if _, err := c.controlURL(context.Background(), func() (*launcher.Launcher, error) {
+			return c.newBrwsrLauncher(context.Background(), true)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "Local State")); err != nil {
+			t.Errorf("user data directory is removed: %v", err)
+		}
+	})
+	t.Run("removes the temporary directory", func(t *testing.T) {
+		c := &Client{opts: options{localBrowser: bin, lg: discardLogger()}}
+		l, err := c.newBrwsrLauncher(context.Background(), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmp := l.Get(flags.UserDataDir)
+		if err := os.MkdirAll(tmp, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+			t.Errorf("temporary user data directory %s is not removed: %v", tmp, err)
+		}
+	})
+}
@@ -0,0 +1,612 @@
+package slackauth
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod/lib/launcher"
+)
+
+//go:generate go run bundled_sums_gen.go
+
+var (
+	// ErrNotInstalled is returned when the bundled browser is not installed.
+	ErrNotInstalled = errors.New("bundled browser is not installed")
+	// ErrIntegrity is returned when the bundled browser files or the
+	// archive do not match the expected checksums.
+	ErrIntegrity = errors.New("bundled browser integrity check failed")
+	// ErrNoChecksum is returned when the checksum of the browser archive is
+	// not pinned for the revision and the platform, and is not provided.
+	ErrNoChecksum = errors.New("no checksum for the bundled browser archive")
+)
+
+// BundledBrowser is the Chromium snapshot that is downloaded and managed by
+// slackauth.  It is used when there are no browsers installed on the system,
+// or when [WithBundledBrowser] is set.  The zero value uses the default
+// revision and the default cache directory.
+type BundledBrowser struct {
+	// Dir is the cache directory, that contains the downloaded revisions.
+	// If empty, [launcher.DefaultBrowserDir] is used.
+	Dir string
+	// Revision is the Chromium snapshot revision.  If zero,
+	// [launcher.RevisionDefault] is used.
+	Revision int
+	// HTTPClient is the client used to download the browser.  If nil, the
+	// default client is used.
+	HTTPClient *http.Client
+	// ArchiveSum is the hex-encoded SHA-256 checksum of the browser archive
+	// of the Revision for this platform.  If empty, the checksum pinned in
+	// slackauth for the revision is used.  The archive is rejected, if
+	// there's no checksum, or if it does not match.
+	ArchiveSum string
+}
+
+// BundledInfo is the information about the bundled browser installation.
+type BundledInfo struct {
+	Installed bool   // true if the browser executable exists
+	Revision  int    // Chromium snapshot revision
+	Dir       string // installation directory
+	Path      string // path to the browser executable
+	Size      int64  // total size of the installation, in bytes
+}
+
+// sumExt is the extension of the file with the checksums of all files of
+// the installation, in the sha256sum(1) format, that is stored next to the
+// installation directory.
+const sumExt = ".sha256sums"
+
+// lockExt is the extension of the lock file, that serialises the downloads
+// and installations of the revision.
+const lockExt = ".lock"
+
+// lockRetry is the interval between the attempts to acquire the lock.
+const lockRetry = 250 * time.Millisecond
+
+// platform is the platform of the browser archive, the key of the
+// archiveSums.
+const platform = runtime.GOOS + "_" + runtime.GOARCH
+
+// bundledHosts are the download locations of the browser archive, in the
+// order of preference.  It is a variable for testing.
+var bundledHosts = []launcher.Host{launcher.HostGoogle, launcher.HostNPM, launcher.HostPlaywright}
+
+// launcherBrowser returns the rod browser downloader for b.
+func (b BundledBrowser) launcherBrowser(ctx context.Context) *launcher.Browser {
+	lb := launcher.NewBrowser()
+	lb.Context = ctx
+	lb.Logger = log.New(io.Discard, "", 0)
+	lb.HTTPClient = b.HTTPClient
+	if b.Dir != "" {
+		lb.RootDir = b.Dir
+	}
+	if b.Revision != 0 {
+		lb.Revision = b.Revision
+	}
+	return lb
+}
+
+// revision returns the revision of the browser.
+func (b BundledBrowser) revision() int {
+	return b.launcherBrowser(context.Background()).Revision
+}
+
+// archiveSum returns the expected checksum of the browser archive.
+func (b BundledBrowser) archiveSum() (string, error) {
+	if b.ArchiveSum != "" {
+		return b.ArchiveSum, nil
+	}
+	if sum := archiveSums[b.revision()][platform]; sum != "" {
+		return sum, nil
+	}
+	return "", fmt.Errorf("%w: revision %d on %s, set it with WithBundledArchiveSum or BundledBrowser.ArchiveSum", ErrNoChecksum, b.revision(), platform)
+}
+
+// dir returns the installation directory of the revision.
+func (b BundledBrowser) dir() string {
+	return b.launcherBrowser(context.Background()).Dir()
+}
+
+// binPath returns the path to the browser executable.
+func (b BundledBrowser) binPath() string {
+	return b.launcherBrowser(context.Background()).BinPath()
+}
+
+// Info returns the information about the bundled browser installation.
+func (b BundledBrowser) Info() (BundledInfo, error) {
+	lb := b.launcherBrowser(context.Background())
+	info := BundledInfo{
+		Revision: lb.Revision,
+		Dir:      lb.Dir(),
+		Path:     lb.BinPath(),
+	}
+	if _, err := os.Stat(info.Path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return info, nil
+		}
+		return info, err
+	}
+	info.Installed = true
+	err := filepath.WalkDir(info.Dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi, err := d.Info(); err == nil && fi.Mode().IsRegular() {
+			info.Size += fi.Size()
+		}
+		return nil
+	})
+	return info, err
+}
+
+// Download downloads the browser archive of the revision, verifies it
+// against the checksum, see [BundledBrowser.ArchiveSum], and installs it,
+// replacing the existing installation.  Concurrent downloads of the same
+// revision, including the ones by the other processes, are serialised.
+func (b BundledBrowser) Download(ctx context.Context) error {
+	unlock, err := b.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return b.download(ctx)
+}
+
+func (b BundledBrowser) download(ctx context.Context) error {
+	sum, err := b.archiveSum()
+	if err != nil {
+		return err
+	}
+	archive, err := b.fetch(ctx, sum)
+	if err != nil {
+		return fmt.Errorf("failed to download the browser: %w", err)
+	}
+	defer os.Remove(archive)
+	return b.install(archive)
+}
+
+// fetch downloads the archive from the first host that has it with the
+// expected checksum, and returns the path to the downloaded file.
+func (b BundledBrowser) fetch(ctx context.Context, sum string) (string, error) {
+	cl := b.HTTPClient
+	if cl == nil {
+		cl = http.DefaultClient
+	}
+	var errs error
+	for _, host := range bundledHosts {
+		archive, err := fetchArchive(ctx, cl, host(b.revision()), filepath.Dir(b.dir()), sum)
+		if err == nil {
+			return archive, nil
+		}
+		errs = errors.Join(errs, err)
+	}
+	return "", errs
+}
+
+// fetchArchive downloads the archive from the url to the temporary file in
+// dir, and checks its checksum.
+func fetchArchive(ctx context.Context, cl *http.Client, url, dir, sum string) (_ string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	f, err := os.CreateTemp(dir, ".download-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
+		f.Close()
+		return "", fmt.Errorf("%s: %w", url, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, sum) {
+		return "", fmt.Errorf("%w: %s checksum is %s, expected %s", ErrIntegrity, url, got, sum)
+	}
+	return f.Name(), nil
+}
+
+// Install installs the browser from the local zip archive, i.e. the
+// "chrome-linux.zip" downloaded from the Chromium snapshots storage on
+// another machine.  The archive must match the checksum sum, if it's not
+// empty, otherwise the one of the revision, see [BundledBrowser.ArchiveSum],
+// and it is rejected with [ErrIntegrity] if it does not.
+func (b BundledBrowser) Install(archive string, sum string) error {
+	if sum == "" {
+		var err error
+		if sum, err = b.archiveSum(); err != nil {
+			return err
+		}
+	}
+	got, err := fileSum(archive)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(got, sum) {
+		return fmt.Errorf("%w: archive %s checksum is %s, expected %s", ErrIntegrity, archive, got, sum)
+	}
+	unlock, err := b.lock(context.Background())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return b.install(archive)
+}
+
+// install extracts the verified archive to the installation directory, and
+// records the checksums of the installed files.
+func (b BundledBrowser) install(archive string) error {
+	dir := b.dir()
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".install-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := unzip(tmp, archive); err != nil {
+		return fmt.Errorf("failed to extract %s: %w", archive, err)
+	}
+	rel, err := filepath.Rel(dir, b.binPath())
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(tmp, rel)); err != nil {
+		return fmt.Errorf("browser executable not found in the archive: %w", err)
+	}
+	if err := b.Remove(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return err
+	}
+	return b.writeSums()
+}
+
+// lock acquires the lock of the revision, waiting for the other downloads
+// and installations of it to finish.  It returns the function that releases
+// the lock.
+func (b BundledBrowser) lock(ctx context.Context) (func() error, error) {
+	dir := b.dir()
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return nil, err
+	}
+	t := time.NewTicker(lockRetry)
+	defer t.Stop()
+	for {
+		unlock, err := lockExclusive(dir + lockExt)
+		if !errors.Is(err, ErrProfileLocked) {
+			return unlock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-t.C:
+		}
+	}
+}
+
+// Verify checks the files of the installation against the checksums,
+// recorded on installation.  It returns [ErrNotInstalled] if the browser is
+// not installed or was installed without the checksums, and [ErrIntegrity]
+// if any file was modified, removed or added.
+func (b BundledBrowser) Verify() error {
+	want, err := b.readSums()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotInstalled
+		}
+		return err
+	}
+	if _, err := os.Stat(b.binPath()); errors.Is(err, fs.ErrNotExist) {
+		return ErrNotInstalled
+	}
+	got, err := dirSums(b.dir())
+	if err != nil {
+		return err
+	}
+	for name, sum := range want {
+		if got[name] != sum {
+			return fmt.Errorf("%w: %s was modified or removed", ErrIntegrity, filepath.Join(b.dir(), filepath.FromSlash(name)))
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			return fmt.Errorf("%w: %s was added", ErrIntegrity, filepath.Join(b.dir(), filepath.FromSlash(name)))
+		}
+	}
+	return nil
+}
+
+// verifyExecutable checks the browser executable against its checksum,
+// recorded on installation.  It is the quick check before each launch, the
+// whole installation is checked by [BundledBrowser.Verify].
+func (b BundledBrowser) verifyExecutable() error {
+	want, err := b.readSums()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotInstalled
+		}
+		return err
+	}
+	rel, err := filepath.Rel(b.dir(), b.binPath())
+	if err != nil {
+		return err
+	}
+	got, err := fileSum(b.binPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotInstalled
+		}
+		return err
+	}
+	if want[filepath.ToSlash(rel)] != got {
+		return fmt.Errorf("%w: %s was modified", ErrIntegrity, b.binPath())
+	}
+	return nil
+}
+
+// Get returns the path to the browser executable, downloading the browser,
+// if it's not installed.  Only the executable is checked against the
+// recorded checksum, call [BundledBrowser.Verify] to check the whole
+// installation.  The existing installation without the checksums, i.e. the
+// one downloaded by rod, is trusted, and its checksums are recorded.
+func (b BundledBrowser) Get(ctx context.Context) (string, error) {
+	err := b.verifyExecutable()
+	switch {
+	case err == nil:
+		return b.binPath(), nil
+	case !errors.Is(err, ErrNotInstalled):
+		return "", err
+	}
+	unlock, err := b.lock(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	// another process may have installed it, while we were waiting.
+	if err := b.verifyExecutable(); err == nil {
+		return b.binPath(), nil
+	} else if !errors.Is(err, ErrNotInstalled) {
+		return "", err
+	}
+	if b.unrecorded() {
+		if err := b.writeSums(); err != nil {
+			return "", err
+		}
+		return b.binPath(), nil
+	}
+	if err := b.download(ctx); err != nil {
+		return "", err
+	}
+	return b.binPath(), nil
+}
+
+// unrecorded returns true, if the browser is installed without the
+// checksums.
+func (b BundledBrowser) unrecorded() bool {
+	if _, err := os.Stat(b.dir() + sumExt); !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	fi, err := os.Stat(b.binPath())
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// Remove removes the browser revision and its checksum.
+func (b BundledBrowser) Remove() error {
+	dir := b.dir()
+	if err := os.Remove(dir + sumExt); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// writeSums records the checksums of the installation files.
+func (b BundledBrowser) writeSums() error {
+	sums, err := dirSums(b.dir())
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(sums[name] + "  " + name + "\n")
+	}
+	return os.WriteFile(b.dir()+sumExt, []byte(sb.String()), 0o644)
+}
+
+// readSums reads the recorded checksums of the installation files.
+func (b BundledBrowser) readSums() (map[string]string, error) {
+	data, err := os.ReadFile(b.dir() + sumExt)
+	if err != nil {
+		return nil, err
+	}
+	sums := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok || len(sum) != sha256.Size*2 || name == "" {
+			return nil, fmt.Errorf("%w: malformed checksum file", ErrIntegrity)
+		}
+		sums[name] = sum
+	}
+	return sums, nil
+}
+
+// dirSums returns the checksums of the files in dir, by the slash-separated
+// relative path.  The checksum of the symlink is the one of its target path.
+func dirSums(dir string) (map[string]string, error) {
+	sums := make(map[string]string)
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		var sum string
+		if d.Type()&fs.ModeSymlink != 0 {
+			link, err := os.Readlink(name)
+			if err != nil {
+				return err
+			}
+			h := sha256.Sum256([]byte(link))
+			sum = hex.EncodeToString(h[:])
+		} else if sum, err = fileSum(name); err != nil {
+			return err
+		}
+		sums[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	return sums, err
+}
+
+// fileSum returns the hex-encoded SHA-256 checksum of the file.
+func fileSum(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// unzip extracts the zip archive to dir.  The top level directory of the
+// archive, i.e. "chrome-linux", is stripped, if all files are inside it.
+// The symlinks must point inside dir, and the files are never written
+// through the extracted symlinks.
+func unzip(dir, archive string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	prefix := commonDir(zr.File)
+	links := make(map[string]bool) // extracted symlinks
+	for _, f := range zr.File {
+		name := strings.TrimSuffix(strings.TrimPrefix(f.Name, prefix), "/")
+		if name == "" {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("invalid file name in archive: %s", f.Name)
+		}
+		for p := name; p != "."; p = path.Dir(p) {
+			if links[p] {
+				return fmt.Errorf("invalid file name in archive: %s goes through the symlink %s", f.Name, p)
+			}
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if f.Mode()&fs.ModeSymlink != 0 {
+			if err := extractLink(target, name, f); err != nil {
+				return err
+			}
+			links[name] = true
+			continue
+		}
+		if err := extractFile(target, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commonDir returns the top level directory with the trailing slash, if all
+// files in the archive are inside it, otherwise it returns an empty string.
+func commonDir(files []*zip.File) string {
+	var prefix string
+	for _, f := range files {
+		top, _, ok := strings.Cut(f.Name, "/")
+		if !ok || (prefix != "" && prefix != top+"/") {
+			return ""
+		}
+		prefix = top + "/"
+	}
+	return prefix
+}
+
+// extractLink extracts the archive symlink f, with the slash-separated
+// name relative to the installation directory, to target.  The link must
+// resolve inside the installation directory.
+func extractLink(target, name string, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	link, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if path.IsAbs(string(link)) || !filepath.IsLocal(filepath.FromSlash(path.Join(path.Dir(name), string(link)))) {
+		return fmt.Errorf("invalid symlink in archive: %s -> %s", f.Name, link)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	return os.Symlink(string(link), target)
+}
+
+// extractFile extracts the archive file or directory f to target.
+func extractFile(target string, f *zip.File) error {
+	mode := f.Mode()
+	if mode.IsDir() {
+		return os.MkdirAll(target, 0o755)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
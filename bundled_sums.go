@@ -0,0 +1,6 @@
+package slackauth
+
+// archiveSums are the SHA-256 checksums of the browser archives, by
+// revision and platform.  They are written by bundled_sums_gen.go, run
+// "go generate" to fill them for the default revision.
+var archiveSums = map[int]map[string]string{}
@@ -0,0 +1,127 @@
+//go:build ignore
+
+// This program downloads the Chromium snapshot archives of the revision for
+// all platforms supported by the bundled browser, and writes their checksums
+// to bundled_sums.go.
+//
+//	go run bundled_sums_gen.go [revision...]
+//
+// If no revisions are given, launcher.RevisionDefault is used.  The file is
+// replaced, so all supported revisions must be listed.
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/go-rod/rod/lib/launcher"
+)
+
+const output = "bundled_sums.go"
+
+// archives are the archive URLs by platform, as in launcher.HostGoogle and
+// launcher.HostPlaywright.
+var archives = map[string]string{
+	"darwin_amd64":  "https://storage.googleapis.com/chromium-browser-snapshots/Mac/%d/chrome-mac.zip",
+	"darwin_arm64":  "https://storage.googleapis.com/chromium-browser-snapshots/Mac_Arm/%d/chrome-mac.zip",
+	"linux_amd64":   "https://storage.googleapis.com/chromium-browser-snapshots/Linux_x64/%d/chrome-linux.zip",
+	"windows_386":   "https://storage.googleapis.com/chromium-browser-snapshots/Win/%d/chrome-win.zip",
+	"windows_amd64": "https://storage.googleapis.com/chromium-browser-snapshots/Win_x64/%d/chrome-win.zip",
+}
+
+// playwrightArm64 is the linux/arm64 archive, that does not depend on the
+// revision.
+var playwrightArm64 = fmt.Sprintf("https://playwright.azureedge.net/builds/chromium/%d/chromium-linux-arm64.zip", launcher.RevisionPlaywright)
+
+func main() {
+	flag.Parse()
+	revs := []int{launcher.RevisionDefault}
+	if flag.NArg() > 0 {
+		revs = revs[:0]
+		for _, arg := range flag.Args() {
+			rev, err := strconv.Atoi(arg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			revs = append(revs, rev)
+		}
+	}
+	all := make(map[int]map[string]string, len(revs))
+	for _, rev := range revs {
+		sums := make(map[string]string)
+		for plat, url := range archives {
+			sum, err := download(fmt.Sprintf(url, rev))
+			if err != nil {
+				log.Fatalf("%s: %s", plat, err)
+			}
+			sums[plat] = sum
+		}
+		sum, err := download(playwrightArm64)
+		if err != nil {
+			log.Fatalf("linux_arm64: %s", err)
+		}
+		sums["linux_arm64"] = sum
+		all[rev] = sums
+	}
+	if err := write(all); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func download(url string) (string, error) {
+	log.Printf("downloading %s", url)
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// write writes the checksums to the output file.
+func write(all map[int]map[string]string) error {
+	var buf bytes.Buffer
+	fmt.Fprint(&buf, "// Code generated by bundled_sums_gen.go; DO NOT EDIT.\n\npackage slackauth\n\n")
+	fmt.Fprint(&buf, "// archiveSums are the SHA-256 checksums of the browser archives, by\n// revision and platform.\n")
+	fmt.Fprint(&buf, "var archiveSums = map[int]map[string]string{\n")
+	revs := make([]int, 0, len(all))
+	for r := range all {
+		revs = append(revs, r)
+	}
+	slices.Sort(revs)
+	for _, r := range revs {
+		fmt.Fprintf(&buf, "%d: {\n", r)
+		plats := make([]string, 0, len(all[r]))
+		for p := range all[r] {
+			plats = append(plats, p)
+		}
+		slices.Sort(plats)
+		for _, p := range plats {
+			fmt.Fprintf(&buf, "%q: %q,\n", p, all[r][p])
+		}
+		fmt.Fprint(&buf, "},\n")
+	}
+	fmt.Fprint(&buf, "}\n")
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, src, 0o644)
+}
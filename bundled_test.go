@@ -0,0 +1,339 @@
+package slackauth
+
+import (
+	"archive/zip"
+	"context"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-rod/rod/lib/launcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mkzip creates the zip archive with the files, and returns its path.
+func mkzip(t *testing.T, files map[string]string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "chrome.zip")
+	f, err := os.Create(name)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	for n, content := range files {
+		w, err := zw.Create(n)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+	return name
+}
+
+// zipEntry is the file or the symlink of the archive, see mkzipLinks.
+type zipEntry struct {
+	name    string
+	content string // the target, if link is set
+	link    bool
+}
+
+// mkzipLinks creates the zip archive with the entries in order, and returns
+// its path.
+func mkzipLinks(t *testing.T, entries ...zipEntry) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "chrome.zip")
+	f, err := os.Create(name)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Store}
+		hdr.SetMode(0o644)
+		if e.link {
+			hdr.SetMode(fs.ModeSymlink | 0o777)
+		}
+		w, err := zw.CreateHeader(hdr)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+	return name
+}
+
+// browserArchive returns the archive with the fake browser executable for
+// b, inside the top level directory, as in the Chromium snapshots.
+func browserArchive(t *testing.T, b BundledBrowser) string {
+	t.Helper()
+	rel, err := filepath.Rel(b.dir(), b.binPath())
+	require.NoError(t, err)
+	return mkzip(t, map[string]string{
+		"chrome-os/" + filepath.ToSlash(rel): "#!/bin/sh\n",
+		"chrome-os/resources.pak":            "resources",
+	})
+}
+
+func TestBundledBrowser_Install(t *testing.T) {
+	b := BundledBrowser{Dir: t.TempDir(), Revision: 123}
+
+	info, err := b.Info()
+	require.NoError(t, err)
+	assert.False(t, info.Installed)
+	assert.Equal(t, 123, info.Revision)
+	assert.ErrorIs(t, b.Verify(), ErrNotInstalled)
+
+	archive := browserArchive(t, b)
+	t.Run("checksum mismatch", func(t *testing.T) {
+		err := b.Install(archive, "0000")
+		assert.ErrorIs(t, err, ErrIntegrity)
+		assert.NoDirExists(t, b.dir())
+	})
+	t.Run("installs and verifies", func(t *testing.T) {
+		sum, err := fileSum(archive)
+		require.NoError(t, err)
+		require.NoError(t, b.Install(archive, sum))
+		assert.NoError(t, b.Verify())
+		assert.FileExists(t, filepath.Join(b.dir(), "resources.pak"))
+
+		info, err := b.Info()
+		require.NoError(t, err)
+		assert.True(t, info.Installed)
+		assert.Equal(t, b.binPath(), info.Path)
+		assert.Equal(t, int64(len("#!/bin/sh\n")+len("resources")), info.Size)
+	})
+	t.Run("detects modification", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(b.binPath(), []byte("tampered"), 0o755))
+		assert.ErrorIs(t, b.Verify(), ErrIntegrity)
+		_, err := b.Get(context.Background())
+		assert.ErrorIs(t, err, ErrIntegrity)
+	})
+	t.Run("detects modification of other files", func(t *testing.T) {
+		sum, err := fileSum(archive)
+		require.NoError(t, err)
+		require.NoError(t, b.Install(archive, sum))
+		require.NoError(t, os.WriteFile(filepath.Join(b.dir(), "resources.pak"), []byte("tampered"), 0o644))
+		assert.ErrorIs(t, b.Verify(), ErrIntegrity)
+
+		require.NoError(t, b.Install(archive, sum))
+		require.NoError(t, os.WriteFile(filepath.Join(b.dir(), "extra.so"), []byte("x"), 0o644))
+		assert.ErrorIs(t, b.Verify(), ErrIntegrity)
+
+		require.NoError(t, b.Install(archive, sum))
+		require.NoError(t, os.Remove(filepath.Join(b.dir(), "resources.pak")))
+		assert.ErrorIs(t, b.Verify(), ErrIntegrity)
+	})
+	t.Run("no pinned checksum", func(t *testing.T) {
+		assert.ErrorIs(t, b.Install(archive, ""), ErrNoChecksum)
+	})
+	t.Run("remove", func(t *testing.T) {
+		require.NoError(t, b.Remove())
+		assert.NoDirExists(t, b.dir())
+		assert.NoFileExists(t, b.dir()+sumExt)
+		assert.ErrorIs(t, b.Verify(), ErrNotInstalled)
+	})
+}
+
+func TestBundledBrowser_Install_noExecutable(t *testing.T) {
+	b := BundledBrowser{Dir: t.TempDir(), Revision: 123}
+	archive := mkzip(t, map[string]string{"chrome-os/README": "hi"})
+	sum, err := fileSum(archive)
+	require.NoError(t, err)
+	assert.ErrorContains(t, b.Install(archive, sum), "executable not found")
+	assert.NoDirExists(t, b.dir())
+}
+
+// archiveServer serves the archive, and counts the downloads.  It replaces
+// the bundledHosts for the duration of the test, the first host does not
+// have the archive.
+func archiveServer(t *testing.T, archive string) *atomic.Int32 {
+	t.Helper()
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chrome.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		n.Add(1)
+		http.ServeFile(w, r, archive)
+	}))
+	t.Cleanup(srv.Close)
+	old := bundledHosts
+	t.Cleanup(func() { bundledHosts = old })
+	bundledHosts = []launcher.Host{
+		func(int) string { return srv.URL + "/missing" },
+		func(int) string { return srv.URL + "/chrome.zip" },
+	}
+	return &n
+}
+
+func TestBundledBrowser_Get(t *testing.T) {
+	ctx := context.Background()
+	t.Run("downloads and verifies the archive", func(t *testing.T) {
+		b := BundledBrowser{Dir: t.TempDir(), Revision: 123}
+		archive := browserArchive(t, b)
+		n := archiveServer(t, archive)
+		sum, err := fileSum(archive)
+		require.NoError(t, err)
+		b.ArchiveSum = sum
+
+		got, err := b.Get(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, b.binPath(), got)
+		assert.NoError(t, b.Verify())
+		_, err = b.Get(ctx)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, n.Load(), "must not download again")
+
+		// only the executable is checked before the launch.
+		require.NoError(t, os.WriteFile(filepath.Join(b.dir(), "resources.pak"), []byte("modified"), 0o644))
+		_, err = b.Get(ctx)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, b.Verify(), ErrIntegrity)
+		require.NoError(t, os.WriteFile(b.binPath(), []byte("modified"), 0o755))
+		_, err = b.Get(ctx)
+		assert.ErrorIs(t, err, ErrIntegrity)
+	})
+	t.Run("checksum mismatch", func(t *testing.T) {
+		b := BundledBrowser{Dir: t.TempDir(), Revision: 123, ArchiveSum: "0000"}
+		archiveServer(t, browserArchive(t, b))
+		_, err := b.Get(ctx)
+		assert.ErrorIs(t, err, ErrIntegrity)
+		assert.NoDirExists(t, b.dir())
+		entries, err := os.ReadDir(b.Dir)
+		require.NoError(t, err)
+		for _, e := range entries {
+			assert.NotContains(t, e.Name(), ".download-", "downloaded archive is not removed")
+		}
+	})
+	t.Run("no checksum", func(t *testing.T) {
+		b := BundledBrowser{Dir: t.TempDir(), Revision: 123}
+		n := archiveServer(t, browserArchive(t, b))
+		_, err := b.Get(ctx)
+		assert.ErrorIs(t, err, ErrNoChecksum)
+		assert.Zero(t, n.Load())
+	})
+	t.Run("installed without checksums", func(t *testing.T) {
+		b := BundledBrowser{Dir: t.TempDir(), Revision: 123}
+		n := archiveServer(t, browserArchive(t, b))
+		require.NoError(t, os.MkdirAll(filepath.Dir(b.binPath()), 0o755))
+		require.NoError(t, os.WriteFile(b.binPath(), []byte("#!/bin/sh\n"), 0o755))
+
+		got, err := b.Get(ctx)
+		require.NoError(t, err, "the existing installation is trusted")
+		assert.Equal(t, b.binPath(), got)
+		assert.Zero(t, n.Load())
+		assert.FileExists(t, b.dir()+sumExt)
+		assert.NoError(t, b.Verify())
+	})
+	t.Run("checksum option", func(t *testing.T) {
+		b := BundledBrowser{Dir: t.TempDir(), Revision: 123}
+		archive := browserArchive(t, b)
+		archiveServer(t, archive)
+		sum, err := fileSum(archive)
+		require.NoError(t, err)
+
+		var o options
+		WithBundledArchiveSum(sum)(&o)
+		b.ArchiveSum = o.bundled.ArchiveSum
+		_, err = b.Get(ctx)
+		assert.NoError(t, err)
+	})
+	t.Run("concurrent downloads", func(t *testing.T) {
+		b := BundledBrowser{Dir: t.TempDir(), Revision: 123}
+		archive := browserArchive(t, b)
+		n := archiveServer(t, archive)
+		sum, err := fileSum(archive)
+		require.NoError(t, err)
+		b.ArchiveSum = sum
+
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := b.Get(ctx)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		assert.EqualValues(t, 1, n.Load(), "must download once")
+	})
+	t.Run("lock honours the context", func(t *testing.T) {
+		b := BundledBrowser{Dir: t.TempDir(), Revision: 123}
+		unlock, err := b.lock(ctx)
+		require.NoError(t, err)
+		defer unlock()
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = b.lock(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func Test_unzip(t *testing.T) {
+	t.Run("strips the top level directory", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, unzip(dir, mkzip(t, map[string]string{"top/a": "a", "top/sub/b": "b"})))
+		assert.FileExists(t, filepath.Join(dir, "a"))
+		assert.FileExists(t, filepath.Join(dir, "sub", "b"))
+	})
+	t.Run("keeps the files without the common directory", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, unzip(dir, mkzip(t, map[string]string{"one/a": "a", "two/b": "b"})))
+		assert.FileExists(t, filepath.Join(dir, "one", "a"))
+		assert.FileExists(t, filepath.Join(dir, "two", "b"))
+	})
+	t.Run("rejects path traversal", func(t *testing.T) {
+		err := unzip(t.TempDir(), mkzip(t, map[string]string{"../evil": "x", "ok": "y"}))
+		assert.Error(t, err)
+	})
+	if runtime.GOOS == "windows" {
+		return
+	}
+	t.Run("extracts the symlinks inside the directory", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, unzip(dir, mkzipLinks(t,
+			zipEntry{name: "top/Versions/A/lib", content: "lib"},
+			zipEntry{name: "top/Versions/Current", content: "A", link: true},
+			zipEntry{name: "top/lib", content: "Versions/Current/lib", link: true},
+		)))
+		data, err := os.ReadFile(filepath.Join(dir, "lib"))
+		require.NoError(t, err)
+		assert.Equal(t, "lib", string(data))
+	})
+	t.Run("rejects the symlinks outside the directory", func(t *testing.T) {
+		for _, target := range []string{"/etc", "../../x", "a/../../../x"} {
+			base := t.TempDir()
+			dir := filepath.Join(base, "install")
+			err := unzip(dir, mkzipLinks(t,
+				zipEntry{name: "top/a", content: "a"},
+				zipEntry{name: "top/sub/link", content: target, link: true},
+			))
+			assert.Error(t, err, target)
+		}
+	})
+	t.Run("rejects the files through the symlinks", func(t *testing.T) {
+		dir := t.TempDir()
+		err := unzip(dir, mkzipLinks(t,
+			zipEntry{name: "top/real/a", content: "a"},
+			zipEntry{name: "top/link", content: "real", link: true},
+			zipEntry{name: "top/link/b", content: "b"},
+		))
+		assert.Error(t, err)
+		assert.NoFileExists(t, filepath.Join(dir, "real", "b"))
+
+		err = unzip(t.TempDir(), mkzipLinks(t,
+			zipEntry{name: "top/a", content: "a"},
+			zipEntry{name: "top/link", content: "a", link: true},
+			zipEntry{name: "top/link", content: "overwritten"},
+		))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,517 @@
+package slackauth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/cdp"
+)
+
+// ErrReplayMismatch is returned by the replayed browser, when the client
+// makes the call that is not in the recording, i.e. the login flow has
+// changed since the session was recorded.
+var ErrReplayMismatch = errors.New("call not found in the CDP recording")
+
+// cdpMessage is the line of the CDP recording.
+type cdpMessage struct {
+	Type string `json:"type"` // "header", "call" or "event"
+
+	// header
+	Workspace string `json:"workspace,omitempty"`
+	Version   string `json:"version,omitempty"`
+
+	Seq       int             `json:"seq,omitempty"`   // call number
+	After     int             `json:"after,omitempty"` // event: number of calls completed before it
+	SessionID string          `json:"session,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *cdp.Error      `json:"error,omitempty"`    // protocol error
+	ErrText   string          `json:"err_text,omitempty"` // other errors
+	Canceled  bool            `json:"canceled,omitempty"` // call ended by the context
+
+	used bool // replayed
+}
+
+const (
+	msgHeader = "header"
+	msgCall   = "call"
+	msgEvent  = "event"
+)
+
+func (m *cdpMessage) key() string {
+	return callKey(m.SessionID, m.Method, m.Params)
+}
+
+func callKey(sessionID, method string, params []byte) string {
+	return sessionID + "\x00" + method + "\x00" + string(params)
+}
+
+// marshalParams marshals the call parameters the same way for recording and
+// replay.
+func marshalParams(params any) (json.RawMessage, error) {
+	if params == nil {
+		return nil, nil
+	}
+	return json.Marshal(params)
+}
+
+// cdpRecorder records the CDP messages between the client and the browser.
+// The secrets are redacted, when the recording is written, once the client
+// knows the token and the cookies.
+type cdpRecorder struct {
+	header cdpMessage
+	sec    *secrets
+
+	mu   sync.Mutex
+	seq  int // calls completed
+	msgs []cdpMessage
+}
+
+func newCDPRecorder(workspace string, sec *secrets) *cdpRecorder {
+	return &cdpRecorder{
+		header: cdpMessage{Type: msgHeader, Workspace: workspace, Version: moduleVersion()},
+		sec:    sec,
+	}
+}
+
+// wrap returns the CDP client, that records the calls and the events of cl.
+func (r *cdpRecorder) wrap(cl rod.CDPClient) rod.CDPClient {
+	rc := &recordingClient{r: r, cl: cl, eventC: make(chan *cdp.Event)}
+	go rc.forward()
+	return rc
+}
+
+func (r *cdpRecorder) addCall(sessionID, method string, params, result []byte, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	m := cdpMessage{Type: msgCall, Seq: r.seq, SessionID: sessionID, Method: method, Params: params, Result: result}
+	var cerr *cdp.Error
+	switch {
+	case err == nil:
+	case errors.As(err, &cerr):
+		m.Error = cerr
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		m.Canceled = true
+	default:
+		m.ErrText = err.Error()
+	}
+	r.msgs = append(r.msgs, m)
+}
+
+func (r *cdpRecorder) addEvent(e *cdp.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, cdpMessage{Type: msgEvent, After: r.seq, SessionID: e.SessionID, Method: e.Method, Params: e.Params})
+}
+
+// WriteTo writes the recording as JSON lines, the header first.  The
+// secrets are redacted.
+func (r *cdpRecorder) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cw := &countWriter{w: w}
+	enc := json.NewEncoder(cw)
+	if err := enc.Encode(r.header); err != nil {
+		return cw.n, err
+	}
+	for _, m := range r.msgs {
+		if err := enc.Encode(r.redact(m)); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, nil
+}
+
+// redact returns the message with the secrets redacted.
+func (r *cdpRecorder) redact(m cdpMessage) cdpMessage {
+	m.Params = r.sec.redactJSON(m.Params)
+	m.Result = r.sec.redactJSON(m.Result)
+	m.ErrText = r.sec.redact(m.ErrText)
+	if m.Error != nil {
+		cerr := *m.Error
+		cerr.Message, cerr.Data = r.sec.redact(cerr.Message), r.sec.redact(cerr.Data)
+		m.Error = &cerr
+	}
+	return m
+}
+
+// save saves the recording to path.
+func (r *cdpRecorder) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	if _, err := r.WriteTo(bw); err != nil {
+		f.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// recordingClient is the CDP client, that records the traffic of the
+// underlying client.
+type recordingClient struct {
+	r      *cdpRecorder
+	cl     rod.CDPClient
+	eventC chan *cdp.Event
+}
+
+func (rc *recordingClient) forward() {
+	defer close(rc.eventC)
+	for e := range rc.cl.Event() {
+		rc.r.addEvent(e)
+		rc.eventC <- e
+	}
+}
+
+func (rc *recordingClient) Event() <-chan *cdp.Event {
+	return rc.eventC
+}
+
+func (rc *recordingClient) Call(ctx context.Context, sessionID, method string, params any) ([]byte, error) {
+	res, err := rc.cl.Call(ctx, sessionID, method, params)
+	p, merr := marshalParams(params)
+	if merr != nil {
+		return res, err
+	}
+	rc.r.addCall(sessionID, method, p, res, err)
+	return res, err
+}
+
+// reObjectID matches the remote object ID in the compact JSON.
+var reObjectID = regexp.MustCompile(`"objectId":"([^"\\]*)"`)
+
+// objectIDs returns the remote object IDs in data, in order.
+func objectIDs(data []byte) []string {
+	var ids []string
+	for _, m := range reObjectID.FindAllSubmatch(data, -1) {
+		ids = append(ids, string(m[1]))
+	}
+	return ids
+}
+
+// shape returns data without the remote object IDs.
+func shape(data []byte) []byte {
+	return reObjectID.ReplaceAll(data, []byte(`"objectId":""`))
+}
+
+// renameObjects replaces the remote object IDs in data by names, the IDs
+// not in names are left as is.
+func renameObjects(data []byte, names map[string]string) []byte {
+	if len(names) == 0 {
+		return data
+	}
+	return reObjectID.ReplaceAllFunc(data, func(b []byte) []byte {
+		id := string(reObjectID.FindSubmatch(b)[1])
+		if n, ok := names[id]; ok {
+			return []byte(`"objectId":"` + n + `"`)
+		}
+		return b
+	})
+}
+
+// cdpReplayer is the CDP client, that replays the recording without the
+// browser.  The calls are matched by the session, the method and the
+// parameters, the repeated calls get the recorded replies in order, and the
+// last reply, once the recorded ones are exhausted, so that the polling
+// does not have to repeat the exact number of times.  The events are emitted
+// once the call, that preceded them in the recording, is made.
+//
+// The recording has the secrets redacted, so are the parameters of the
+// replayed calls, before they are matched, and the replay gets the redacted
+// token and cookies.
+//
+// The concurrent calls with the same parameters may get the replies in the
+// order, that differs from the recording, and then use the remote objects of
+// each other.  Such calls are matched up to the object IDs, and the replayer
+// renames the objects of the replay to the recorded ones from then on.
+type cdpReplayer struct {
+	header cdpMessage
+	sec    *secrets
+
+	mu      sync.Mutex
+	calls   map[string][]*cdpMessage // replies by call key
+	shapes  map[string][]*cdpMessage // replies by call key without object IDs
+	last    map[string]*cdpMessage   // last reply by call key
+	ids     map[string]string        // replay object IDs to the recorded ones
+	rids    map[string]string        // recorded object IDs to the replay ones
+	events  []*cdpMessage
+	next    int // next event to emit
+	eventC  chan *cdp.Event
+	started bool
+	closed  bool
+}
+
+// loadCDPRecording loads the recording from path.
+func loadCDPRecording(path string) (*cdpReplayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readCDPRecording(f)
+}
+
+func readCDPRecording(r io.Reader) (*cdpReplayer, error) {
+	rp := &cdpReplayer{
+		calls:  make(map[string][]*cdpMessage),
+		shapes: make(map[string][]*cdpMessage),
+		last:   make(map[string]*cdpMessage),
+		ids:    make(map[string]string),
+		rids:   make(map[string]string),
+	}
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var m cdpMessage
+		if err := dec.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("CDP recording line %d: %w", line, err)
+		}
+		switch m.Type {
+		case msgHeader:
+			rp.header = m
+		case msgCall:
+			m.Params = compact(m.Params)
+			rp.calls[m.key()] = append(rp.calls[m.key()], &m)
+			skey := callKey(m.SessionID, m.Method, shape(m.Params))
+			rp.shapes[skey] = append(rp.shapes[skey], &m)
+		case msgEvent:
+			rp.events = append(rp.events, &m)
+		default:
+			return nil, fmt.Errorf("CDP recording line %d: unknown message type %q", line, m.Type)
+		}
+	}
+	if rp.header.Type != msgHeader {
+		return nil, errors.New("CDP recording: missing header")
+	}
+	rp.eventC = make(chan *cdp.Event, len(rp.events))
+	return rp, nil
+}
+
+// compact removes the insignificant space from the JSON, so that the
+// parameters can be compared.
+func compact(data json.RawMessage) json.RawMessage {
+	if len(data) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return data
+	}
+	return buf.Bytes()
+}
+
+// start emits the events, that preceded the first call, and closes the
+// events channel, when ctx is done.  The rest of the events are emitted
+// only by the calls, so that the replay follows the recorded order.
+func (rp *cdpReplayer) start(ctx context.Context) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.started {
+		return
+	}
+	rp.started = true
+	rp.flush(0)
+	context.AfterFunc(ctx, func() {
+		rp.mu.Lock()
+		defer rp.mu.Unlock()
+		rp.closed = true
+		close(rp.eventC)
+	})
+}
+
+// flush emits the events, that followed the call seq or earlier, must be
+// called with the mutex held.
+func (rp *cdpReplayer) flush(seq int) {
+	for ; rp.next < len(rp.events) && rp.events[rp.next].After <= seq; rp.next++ {
+		if rp.closed {
+			continue
+		}
+		e := rp.events[rp.next]
+		rp.eventC <- &cdp.Event{SessionID: e.SessionID, Method: e.Method, Params: renameObjects(e.Params, rp.rids)}
+	}
+}
+
+func (rp *cdpReplayer) Event() <-chan *cdp.Event {
+	return rp.eventC
+}
+
+func (rp *cdpReplayer) Call(ctx context.Context, sessionID, method string, params any) ([]byte, error) {
+	p, err := marshalParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	rp.mu.Lock()
+	m, ok := rp.reply(sessionID, method, rp.sec.redactJSON(compact(p)))
+	if !ok {
+		rp.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s %s", ErrReplayMismatch, method, p)
+	}
+	rp.flush(m.Seq)
+	result := renameObjects(m.Result, rp.rids)
+	rp.mu.Unlock()
+
+	switch {
+	case m.Canceled:
+		<-ctx.Done()
+		return nil, ctx.Err()
+	case m.Error != nil:
+		cerr := *m.Error
+		return nil, &cerr
+	case m.ErrText != "":
+		return nil, errors.New(m.ErrText)
+	}
+	return result, nil
+}
+
+// reply returns the next recorded reply to the call, must be called with the
+// mutex held.
+func (rp *cdpReplayer) reply(sessionID, method string, params []byte) (*cdpMessage, bool) {
+	params = renameObjects(params, rp.ids)
+	key := callKey(sessionID, method, params)
+	for q := rp.calls[key]; len(q) > 0; q = rp.calls[key] {
+		rp.calls[key] = q[1:]
+		if m := q[0]; !m.used {
+			m.used = true
+			rp.last[key] = m
+			return m, true
+		}
+	}
+	if m, ok := rp.last[key]; ok {
+		return m, true
+	}
+	// the call on the objects, that the concurrent call got in the recording.
+	ids := objectIDs(params)
+	for _, m := range rp.shapes[callKey(sessionID, method, shape(params))] {
+		if m.used || !rp.rename(ids, objectIDs(m.Params)) {
+			continue
+		}
+		m.used = true
+		rp.last[m.key()] = m
+		return m, true
+	}
+	return nil, false
+}
+
+// rename renames the objects, so that the objects with the recorded IDs
+// from, as they are renamed now, get the recorded IDs to.  It returns false, if the IDs
+// can't be mapped one to one.  Must be called with the mutex held.
+func (rp *cdpReplayer) rename(from, to []string) bool {
+	if len(from) != len(to) {
+		return false
+	}
+	fwd, rev := make(map[string]string), make(map[string]string)
+	for i := range from {
+		if f, ok := fwd[from[i]]; ok && f != to[i] {
+			return false
+		}
+		if r, ok := rev[to[i]]; ok && r != from[i] {
+			return false
+		}
+		fwd[from[i]], rev[to[i]] = to[i], from[i]
+	}
+	objs := make([]string, len(from))
+	for i := range from {
+		objs[i] = rp.replayID(from[i])
+	}
+	for i, obj := range objs {
+		if id := rp.recordedID(obj); id != to[i] {
+			rp.swap(id, to[i])
+		}
+	}
+	return true
+}
+
+// swap swaps the recorded object IDs a and b, so that the replay object,
+// that was a, becomes b, and vice versa.  Must be called with the mutex held.
+func (rp *cdpReplayer) swap(a, b string) {
+	ra, rb := rp.replayID(a), rp.replayID(b)
+	rp.ids[ra], rp.ids[rb] = b, a
+	rp.rids[a], rp.rids[b] = rb, ra
+}
+
+// recordedID returns the recorded object ID of the replay one.
+func (rp *cdpReplayer) recordedID(id string) string {
+	if r, ok := rp.ids[id]; ok {
+		return r
+	}
+	return id
+}
+
+// replayID returns the replay object ID of the recorded one.
+func (rp *cdpReplayer) replayID(id string) string {
+	if r, ok := rp.rids[id]; ok {
+		return r
+	}
+	return id
+}
+
+// initCDPSession sets up the recording or the replay of the CDP session, if
+// requested.
+func (c *Client) initCDPSession() error {
+	switch {
+	case c.opts.cdpReplay != "":
+		rp, err := loadCDPRecording(c.opts.cdpReplay)
+		if err != nil {
+			return fmt.Errorf("failed to load CDP recording: %w", err)
+		}
+		if rp.header.Workspace != c.wspURL {
+			return fmt.Errorf("CDP recording is for the workspace %s, not %s", rp.header.Workspace, c.wspURL)
+		}
+		rp.sec = c.secrets
+		c.cdpPlay = rp
+	case c.opts.cdpRecord != "":
+		c.cdpRec = newCDPRecorder(c.wspURL, c.secrets)
+		// registered first, so that it runs last, after the pages and the
+		// browser are closed.
+		c.atClose(func() error {
+			if err := c.cdpRec.save(c.opts.cdpRecord); err != nil {
+				return fmt.Errorf("failed to save CDP recording: %w", err)
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// connect connects the browser to the control URL, recording the session,
+// if [WithCDPRecording] is set, or connects it to the recorded session, if
+// [WithCDPReplay] is set.
+func (c *Client) connect(ctx context.Context, b *rod.Browser, url string) error {
+	switch {
+	case c.cdpPlay != nil:
+		c.cdpPlay.start(ctx)
+		b = b.Client(c.cdpPlay)
+	case c.cdpRec != nil:
+		cl, err := cdp.StartWithURL(ctx, url, nil)
+		if err != nil {
+			return err
+		}
+		b = b.Client(c.cdpRec.wrap(cl))
+	default:
+		b = b.ControlURL(url)
+	}
+	return b.Connect()
+}
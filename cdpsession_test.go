@@ -0,0 +1,381 @@
+package slackauth
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/cdp"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ysmood/gson"
+
+	"github.com/rusq/slackauth/slackauthtest"
+)
+
+const fakeVersion = `{"protocolVersion":"1.3","product":"HeadlessChrome/120.0.6099.0","revision":"@1","userAgent":"Mozilla/5.0 HeadlessChrome/120.0.6099.0","jsVersion":"12.0"}`
+
+// fakeBrowser is the CDP client, that replies to the known methods, and
+// records the called methods.
+type fakeBrowser struct {
+	results map[string]string
+	eventC  chan *cdp.Event
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func newFakeBrowser() *fakeBrowser {
+	return &fakeBrowser{
+		results: map[string]string{
+			"Target.setDiscoverTargets": `{}`,
+			"Browser.getVersion":        fakeVersion,
+		},
+		eventC: make(chan *cdp.Event, 1),
+	}
+}
+
+func (f *fakeBrowser) Event() <-chan *cdp.Event {
+	return f.eventC
+}
+
+func (f *fakeBrowser) Call(_ context.Context, _, method string, _ any) ([]byte, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, method)
+	f.mu.Unlock()
+	res, ok := f.results[method]
+	if !ok {
+		return nil, &cdp.Error{Code: -32601, Message: "'" + method + "' wasn't found"}
+	}
+	return []byte(res), nil
+}
+
+func TestCDPRecording(t *testing.T) {
+	rec := newCDPRecorder("https://acme.slack.com/", nil)
+	fake := newFakeBrowser()
+
+	b := rod.New().Client(rec.wrap(fake))
+	require.NoError(t, b.Connect())
+	want, err := b.Version()
+	require.NoError(t, err)
+	_, err = proto.BrowserGetWindowForTarget{}.Call(b)
+	require.Error(t, err)
+	fake.eventC <- &cdp.Event{Method: "Target.targetCreated", Params: json.RawMessage(`{"targetInfo":{"targetId":"T1"}}`)}
+	assert.Eventually(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return len(rec.msgs) == 4
+	}, time.Second, 10*time.Millisecond)
+
+	var sb strings.Builder
+	_, err = rec.WriteTo(&sb)
+	require.NoError(t, err)
+	var types []string
+	sc := bufio.NewScanner(strings.NewReader(sb.String()))
+	for sc.Scan() {
+		var m cdpMessage
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &m))
+		types = append(types, m.Type+" "+m.Method)
+	}
+	assert.Equal(t, []string{
+		"header ",
+		"call Target.setDiscoverTargets",
+		"call Browser.getVersion",
+		"call Browser.getWindowForTarget",
+		"event Target.targetCreated",
+	}, types)
+
+	// replay
+	rp, err := readCDPRecording(strings.NewReader(sb.String()))
+	require.NoError(t, err)
+	assert.Equal(t, "https://acme.slack.com/", rp.header.Workspace)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rp.start(ctx)
+
+	b = rod.New().Context(ctx).Client(rp)
+	require.NoError(t, b.Connect())
+	got, err := b.Version()
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	_, err = proto.BrowserGetWindowForTarget{}.Call(b)
+	assert.ErrorIs(t, err, &cdp.Error{Code: -32601, Message: "'Browser.getWindowForTarget' wasn't found"})
+	err = proto.BrowserClose{}.Call(b)
+	assert.ErrorIs(t, err, ErrReplayMismatch)
+}
+
+func Test_cdpRecorder_redact(t *testing.T) {
+	sec := new(secrets)
+	sec.add(testSecret)
+	rec := newCDPRecorder("https://acme.slack.com/", sec)
+	p, err := marshalParams(map[string]string{"text": testSecret})
+	require.NoError(t, err)
+	rec.addCall("S1", "Input.insertText", p, []byte(`{}`), nil)
+	rec.addCall("S1", "Storage.getCookies", []byte(`{}`), []byte(`{"cookies":[{"name":"d","value":"xoxd-a%2Fb%3D"}]}`), nil)
+	rec.addCall("S1", "Fetch.fulfillRequest", []byte(`{}`), nil, &cdp.Error{Code: -32602, Message: "Invalid parameters", Data: "token xoxc-1-2-3"})
+	rec.addCall("S1", "Fetch.continueRequest", []byte(`{}`), nil, errors.New("connection reset by "+testSecret))
+	rec.addEvent(&cdp.Event{SessionID: "S1", Method: "Fetch.requestPaused", Params: json.RawMessage(`{"request":{"postData":"token=xoxc-1-2-3"}}`)})
+
+	var sb strings.Builder
+	_, err = rec.WriteTo(&sb)
+	require.NoError(t, err)
+	got := sb.String()
+	for _, secret := range []string{"w0rd", "xoxc-", "xoxd-"} {
+		assert.NotContains(t, got, secret)
+	}
+	assert.Equal(t, 5, strings.Count(got, redacted))
+	assert.Contains(t, rec.msgs[0].Params.String(), "w0rd", "the recorded messages are kept as is")
+}
+
+// recording is the handcrafted recording for the replayer tests.
+const recording = `{"type":"header","workspace":"https://acme.slack.com/"}
+{"type":"event","method":"Target.targetCreated","params":{"n":0}}
+{"type":"call","seq":1,"method":"Runtime.evaluate","params":{"expression":"1"},"result":{"n":1}}
+{"type":"event","after":1,"method":"Page.loadEventFired","params":{"n":1}}
+{"type":"call","seq":2,"session":"S1","method":"Runtime.evaluate","params":{"expression":"1"},"result":{"n":2}}
+{"type":"call","seq":3,"method":"Runtime.evaluate","params":{"expression":"1"},"result":{"n":3}}
+{"type":"event","after":3,"method":"Page.frameNavigated","params":{"n":3}}
+{"type":"call","seq":4,"method":"Page.navigate","params":{"url":"https://acme.slack.com/"},"canceled":true}
+{"type":"call","seq":5,"method":"Page.reload","params":{},"err_text":"connection reset"}
+`
+
+func Test_cdpReplayer(t *testing.T) {
+	rp, err := readCDPRecording(strings.NewReader(recording))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rp.start(ctx)
+
+	nextEvent := func() string {
+		select {
+		case e := <-rp.Event():
+			return e.Method
+		default:
+			return ""
+		}
+	}
+	call := func(sessionID, method string, params any) string {
+		res, err := rp.Call(ctx, sessionID, method, params)
+		require.NoError(t, err)
+		return string(res)
+	}
+	eval := map[string]string{"expression": "1"}
+
+	assert.Equal(t, "Target.targetCreated", nextEvent(), "events before the first call")
+	assert.Equal(t, "", nextEvent())
+
+	assert.Equal(t, `{"n":1}`, call("", "Runtime.evaluate", eval))
+	assert.Equal(t, "Page.loadEventFired", nextEvent())
+	assert.Equal(t, `{"n":3}`, call("", "Runtime.evaluate", eval), "replies in order")
+	assert.Equal(t, "Page.frameNavigated", nextEvent())
+	assert.Equal(t, `{"n":3}`, call("", "Runtime.evaluate", eval), "last reply repeats")
+	assert.Equal(t, `{"n":2}`, call("S1", "Runtime.evaluate", eval), "matched by session")
+	assert.Equal(t, "", nextEvent())
+
+	_, err = rp.Call(ctx, "", "Runtime.evaluate", map[string]string{"expression": "2"})
+	assert.ErrorIs(t, err, ErrReplayMismatch)
+	_, err = rp.Call(ctx, "", "Page.reload", struct{}{})
+	assert.EqualError(t, err, "connection reset")
+
+	cctx, ccancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer ccancel()
+	_, err = rp.Call(cctx, "", "Page.navigate", map[string]string{"url": "https://acme.slack.com/"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func Test_cdpReplayer_redact(t *testing.T) {
+	const data = `{"type":"header","workspace":"https://acme.slack.com/"}
+{"type":"call","seq":1,"session":"S1","method":"Input.insertText","params":{"text":"[REDACTED]"},"result":{}}
+`
+	rp, err := readCDPRecording(strings.NewReader(data))
+	require.NoError(t, err)
+	rp.sec = new(secrets)
+	rp.sec.add(testSecret)
+
+	_, err = rp.Call(context.Background(), "S1", "Input.insertText", map[string]string{"text": testSecret})
+	assert.NoError(t, err, "the parameters must be redacted before matching")
+}
+
+// concurrentRecording is the recording of two concurrent calls, that got
+// the helper objects A and B, and then called the helpers with the different
+// arguments.
+const concurrentRecording = `{"type":"header","workspace":"https://acme.slack.com/"}
+{"type":"call","seq":1,"method":"Runtime.callFunctionOn","params":{"functionDeclaration":"f","objectId":"W"},"result":{"result":{"objectId":"A"}}}
+{"type":"call","seq":2,"method":"Runtime.callFunctionOn","params":{"functionDeclaration":"f","objectId":"W"},"result":{"result":{"objectId":"B"}}}
+{"type":"call","seq":3,"method":"Runtime.callFunctionOn","params":{"functionDeclaration":"g","objectId":"A","arguments":[{"value":"title"}]},"result":{"n":3}}
+{"type":"call","seq":4,"method":"Runtime.callFunctionOn","params":{"functionDeclaration":"g","objectId":"B","arguments":[{"value":"a"}]},"result":{"n":4}}
+{"type":"call","seq":5,"method":"Runtime.callFunctionOn","params":{"functionDeclaration":"h","objectId":"A"},"result":{"n":5}}
+{"type":"event","after":5,"method":"Runtime.consoleAPICalled","params":{"args":[{"objectId":"A"}]}}
+{"type":"call","seq":6,"method":"Runtime.callFunctionOn","params":{"functionDeclaration":"k","objectId":"W"},"result":{"result":{"objectId":"A"}}}
+`
+
+func Test_cdpReplayer_concurrent(t *testing.T) {
+	rp, err := readCDPRecording(strings.NewReader(concurrentRecording))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rp.start(ctx)
+
+	call := func(fn, objectID string, args ...any) string {
+		res, err := rp.Call(ctx, "", "Runtime.callFunctionOn", proto.RuntimeCallFunctionOn{
+			FunctionDeclaration: fn,
+			ObjectID:            proto.RuntimeRemoteObjectID(objectID),
+			Arguments:           arguments(args...),
+		})
+		require.NoError(t, err)
+		return string(res)
+	}
+
+	// the calls got the helpers in the reverse order: the "title" call has B.
+	assert.Equal(t, `{"result":{"objectId":"A"}}`, call("f", "W"))
+	assert.Equal(t, `{"result":{"objectId":"B"}}`, call("f", "W"))
+	assert.Equal(t, `{"n":3}`, call("g", "B", "title"), "matched up to the object IDs")
+	assert.Equal(t, `{"n":4}`, call("g", "A", "a"), "renamed")
+	assert.Equal(t, `{"n":5}`, call("h", "B"))
+	assert.Equal(t, `{"args":[{"objectId":"B"}]}`, string((<-rp.Event()).Params), "events are renamed")
+	assert.Equal(t, `{"result":{"objectId":"B"}}`, call("k", "W"), "results are renamed")
+
+	assert.Equal(t, `{"n":3}`, call("g", "B", "title"), "last reply repeats")
+
+	_, err = rp.Call(ctx, "", "Runtime.callFunctionOn", proto.RuntimeCallFunctionOn{
+		FunctionDeclaration: "g",
+		ObjectID:            "C",
+		Arguments:           arguments("title"),
+	})
+	assert.ErrorIs(t, err, ErrReplayMismatch, "the recorded calls are replayed once")
+}
+
+func arguments(values ...any) []*proto.RuntimeCallArgument {
+	var args []*proto.RuntimeCallArgument
+	for _, v := range values {
+		args = append(args, &proto.RuntimeCallArgument{Value: gson.New(v)})
+	}
+	return args
+}
+
+func Test_readCDPRecording(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"valid", recording, false},
+		{"no header", `{"type":"call","seq":1,"method":"Page.enable"}`, true},
+		{"unknown type", recording + `{"type":"response"}`, true},
+		{"garbage", "not json", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readCDPRecording(strings.NewReader(tt.data))
+			assert.Equal(t, tt.wantErr, err != nil, "error = %v", err)
+		})
+	}
+}
+
+func TestNewContext_cdpSession(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.jsonl")
+	rec := newCDPRecorder("https://acme.slack.com/", nil)
+	require.NoError(t, rec.save(path))
+
+	c, err := New("acme", WithCDPReplay(path))
+	require.NoError(t, err, "workspace check must be skipped")
+	assert.NotNil(t, c.cdpPlay)
+
+	_, err = New("other", WithCDPReplay(path))
+	assert.ErrorContains(t, err, "is for the workspace https://acme.slack.com/")
+
+	_, err = New("acme", WithCDPReplay(filepath.Join(dir, "missing.jsonl")))
+	assert.Error(t, err)
+
+	_, err = New("acme", WithCDPReplay(path), WithCDPRecording(path))
+	assert.Error(t, err)
+}
+
+// record re-records the sessions in testdata with the browser.
+var record = flag.Bool("record", false, "record the CDP sessions in testdata, requires the browser")
+
+// replayTests are the login flows, that are recorded in testdata.
+var replayTests = []struct {
+	name  string
+	file  string
+	login func(ctx context.Context, c *Client, link string) (string, []*http.Cookie, error)
+}{
+	{"headless", "testdata/headless.jsonl", func(ctx context.Context, c *Client, _ string) (string, []*http.Cookie, error) {
+		return c.Headless(ctx, slackauthtest.DefaultEmail, slackauthtest.DefaultPassword)
+	}},
+	{"login link", "testdata/login_link.jsonl", func(ctx context.Context, c *Client, link string) (string, []*http.Cookie, error) {
+		return c.linkAuth(ctx, link)
+	}},
+}
+
+// assertRedacted checks that the replayed session has the token and the
+// session cookie, redacted in the recording.
+func assertRedacted(t *testing.T, token string, cookies []*http.Cookie) {
+	t.Helper()
+	assert.Equal(t, redacted, token)
+	for _, c := range cookies {
+		if c.Name == cookieD {
+			assert.Equal(t, redacted, c.Value)
+			return
+		}
+	}
+	t.Errorf("session cookie %q not found", cookieD)
+}
+
+func TestCDPReplay(t *testing.T) {
+	for _, tt := range replayTests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp, err := loadCDPRecording(tt.file)
+			require.NoError(t, err)
+			workspace := rp.header.Workspace
+
+			c, err := New(workspace, WithCDPReplay(tt.file), WithAutologinTimeout(e2eTimeout))
+			require.NoError(t, err)
+			defer c.Close()
+			link := workspace + "z-app-" + slackauthtest.TeamID + "-4567890123/redeem"
+			token, cookies, err := tt.login(e2eContext(t), c, link)
+			require.NoError(t, err)
+			assertRedacted(t, token, cookies)
+		})
+	}
+}
+
+// TestE2E_replay records the sessions with the browser, and replays them.
+// With -record, the recordings in testdata are replaced.
+func TestE2E_replay(t *testing.T) {
+	for _, tt := range replayTests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := slackauthtest.NewServer()
+			path := filepath.Join(t.TempDir(), "session.jsonl")
+			if *record {
+				path = tt.file
+			}
+			c := e2eClient(t, srv, WithCDPRecording(path))
+			wantToken, _, err := tt.login(e2eContext(t), c, srv.LoginLink())
+			require.NoError(t, err)
+			assert.Equal(t, slackauthtest.DefaultToken, wantToken)
+			require.NoError(t, c.Close())
+			srv.Close()
+
+			// the server is gone, the replay needs neither the server nor
+			// the browser.
+			r, err := New(srv.URL, WithCDPReplay(path), WithAutologinTimeout(e2eTimeout))
+			require.NoError(t, err)
+			defer r.Close()
+			token, cookies, err := tt.login(e2eContext(t), r, srv.LoginLink())
+			require.NoError(t, err)
+			assertRedacted(t, token, cookies)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package slackauth
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-rod/rod/lib/launcher"
+)
+
+// ErrCertificate is returned when the TLS certificate of the server can't be
+// verified, i.e. behind the TLS-inspecting proxy.  Use [WithRootCAFile] or
+// [WithRootCAs] to trust the proxy certificate authority.
+type ErrCertificate struct {
+	Host string
+	Err  error
+}
+
+func (e ErrCertificate) Error() string {
+	return fmt.Sprintf("certificate verification failed for %s (TLS interception? see WithRootCAFile): %v", e.Host, e.Err)
+}
+
+func (e ErrCertificate) Unwrap() error {
+	return e.Err
+}
+
+// isCertError returns true if err is the certificate verification error.
+func isCertError(err error) bool {
+	var (
+		verr *tls.CertificateVerificationError
+		uerr x509.UnknownAuthorityError
+		herr x509.HostnameError
+		ierr x509.CertificateInvalidError
+	)
+	return errors.As(err, &verr) || errors.As(err, &uerr) || errors.As(err, &herr) || errors.As(err, &ierr)
+}
+
+// certError wraps err in [ErrCertificate], if it is the certificate
+// verification error, otherwise it returns nil.
+func certError(uri string, err error) error {
+	if !isCertError(err) {
+		return nil
+	}
+	host := uri
+	if u, perr := url.Parse(uri); perr == nil && u.Host != "" {
+		host = u.Host
+	}
+	return ErrCertificate{Host: host, Err: err}
+}
+
+// loadCerts loads the PEM-encoded certificates from the file.
+func loadCerts(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s: no certificates found", path)
+	}
+	return certs, nil
+}
+
+// certPool returns the system certificate pool with the certs added.
+func certPool(certs []*x509.Certificate) *x509.CertPool {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, c := range certs {
+		pool.AddCert(c)
+	}
+	return pool
+}
+
+// spkiHash returns the base64-encoded SHA-256 hash of the certificate
+// public key, as expected by the --ignore-certificate-errors-spki-list flag.
+func spkiHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// caFlags makes the browser accept the server certificates, issued by the
+// certs.  Chrome has no command line flag to add the root certificate, but
+// it ignores the certificate errors, if any certificate in the chain has the
+// public key from the SPKI list.  The list is honoured only with the
+// non-default user data directory, see [WithBrowserRootCAs].
+func caFlags(l *launcher.Launcher, certs []*x509.Certificate) *launcher.Launcher {
+	if len(certs) == 0 {
+		return l
+	}
+	hashes := make([]string, len(certs))
+	for i, c := range certs {
+		hashes[i] = spkiHash(c)
+	}
+	return l.Set("ignore-certificate-errors-spki-list", strings.Join(hashes, ","))
+}
@@ -0,0 +1,105 @@
+package slackauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes the certificate of the TLS test server to the PEM file.
+func writeCert(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(name, data, 0o644))
+	return name
+}
+
+func Test_checkWorkspaceURL_certs(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		err := checkWorkspaceURL(context.Background(), options{}.httpClient(), srv.URL)
+		var cerr ErrCertificate
+		require.ErrorAs(t, err, &cerr)
+		assert.Equal(t, srv.Listener.Addr().String(), cerr.Host)
+		assert.NotErrorIs(t, err, ErrWorkspaceNotFound)
+	})
+	t.Run("trusted with the CA file", func(t *testing.T) {
+		var o options
+		WithRootCAFile(writeCert(t, srv))(&o)
+		require.NoError(t, o.err)
+		assert.NoError(t, checkWorkspaceURL(context.Background(), o.httpClient(), srv.URL))
+	})
+	t.Run("trusted with the pool", func(t *testing.T) {
+		var o options
+		WithRootCAs(srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs)(&o)
+		assert.NoError(t, checkWorkspaceURL(context.Background(), o.httpClient(), srv.URL))
+	})
+}
+
+func Test_loadCerts(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	certs, err := loadCerts(writeCert(t, srv))
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+	assert.Equal(t, srv.Certificate().Raw, certs[0].Raw)
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("not a certificate"), 0o644))
+	_, err = loadCerts(empty)
+	assert.Error(t, err)
+}
+
+func Test_caFlags(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	l := caFlags(launcher.New(), nil)
+	_, ok := l.GetFlags("ignore-certificate-errors-spki-list")
+	assert.False(t, ok)
+
+	cert := srv.Certificate()
+	l = caFlags(launcher.New(), []*x509.Certificate{cert, cert})
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	hash := base64.StdEncoding.EncodeToString(sum[:])
+	assert.Equal(t, hash+","+hash, l.Get("ignore-certificate-errors-spki-list"))
+}
+
+func TestWithBrowserRootCAs(t *testing.T) {
+	_, err := New("test", WithBrowserRootCAs())
+	assert.Error(t, err)
+
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	ca := writeCert(t, srv)
+	t.Run("default user profile", func(t *testing.T) {
+		_, err := New("test", WithSkipWorkspaceCheck(), WithRootCAFile(ca), WithBrowserRootCAs(), WithForceUser())
+		assert.ErrorContains(t, err, "WithForceUser")
+	})
+	t.Run("profile snapshot", func(t *testing.T) {
+		_, err := New("test", WithSkipWorkspaceCheck(), WithRootCAFile(ca), WithBrowserRootCAs(), WithProfileSnapshot())
+		assert.NoError(t, err)
+	})
+	t.Run("user data dir flag", func(t *testing.T) {
+		_, err := New("test", WithSkipWorkspaceCheck(), WithRootCAFile(ca), WithBrowserRootCAs(), WithForceUser(),
+			WithBrowserFlags(map[string]string{"--user-data-dir": t.TempDir()}))
+		assert.NoError(t, err)
+	})
+}
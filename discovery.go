@@ -0,0 +1,256 @@
+package slackauth
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvBrowser is the environment variable that overrides the browser
+// discovery.  It should contain the path to the browser executable.
+const EnvBrowser = "SLACKAUTH_BROWSER"
+
+// ErrEnvBrowser is returned when the browser set in the [EnvBrowser]
+// environment variable is not found.
+var ErrEnvBrowser = errors.New(EnvBrowser + " browser not found")
+
+// checkEnvBrowser returns [ErrEnvBrowser], if the browser set in the
+// [EnvBrowser] environment variable is not found, so that the override is
+// not silently replaced with another browser.
+func checkEnvBrowser() error {
+	p := os.Getenv(EnvBrowser)
+	if p == "" {
+		return nil
+	}
+	if _, err := exec.LookPath(p); err != nil {
+		return fmt.Errorf("%w: %w", ErrEnvBrowser, err)
+	}
+	return nil
+}
+
+// Channel is the browser release channel or the packaging.
+type Channel string
+
+const (
+	ChannelStable  Channel = "stable"
+	ChannelBeta    Channel = "beta"
+	ChannelDev     Channel = "dev"
+	ChannelCanary  Channel = "canary"
+	ChannelFlatpak Channel = "flatpak"
+	ChannelSnap    Channel = "snap"
+)
+
+var (
+	registeredMu sync.RWMutex
+	registered   []candidate
+)
+
+// RegisterBrowser registers the additional browser executable for
+// discovery.  Registered browsers take precedence over the built-in ones, in
+// the order of registration.  Path can be the absolute path, or the name of
+// the executable in PATH.
+func RegisterBrowser(name, path string) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registered = append(registered, candidate{Name: name, Path: path})
+}
+
+// candidates returns the list of browser executables to look for, in the
+// order of preference: the environment variable, registered browsers,
+// built-in list and the desktop entries (on Linux).
+func candidates() []candidate {
+	var list []candidate
+	if p := os.Getenv(EnvBrowser); p != "" {
+		list = append(list, candidate{Name: browserName(p), Path: p})
+	}
+	registeredMu.RLock()
+	list = append(list, registered...)
+	registeredMu.RUnlock()
+	list = append(list, browserList...)
+	if runtime.GOOS == "linux" {
+		list = append(list, desktopCandidates(desktopDirs())...)
+	}
+	return list
+}
+
+// detectChannel guesses the release channel by the executable path.
+func detectChannel(path string) Channel {
+	p := strings.ToLower(filepath.ToSlash(path))
+	switch {
+	case strings.Contains(p, "/flatpak/"):
+		return ChannelFlatpak
+	case strings.HasPrefix(p, "/snap/"):
+		return ChannelSnap
+	case strings.Contains(p, "canary") || strings.Contains(p, "nightly") || strings.Contains(p, "chrome sxs"):
+		return ChannelCanary
+	case strings.Contains(p, "beta"):
+		return ChannelBeta
+	case strings.Contains(p, "unstable") || strings.Contains(p, "-dev") || strings.Contains(p, " dev"):
+		return ChannelDev
+	default:
+		return ChannelStable
+	}
+}
+
+// reVersion matches the browser version.
+var reVersion = regexp.MustCompile(`\b(\d+\.\d+\.\d+\.\d+)\b`)
+
+// versionTimeout is the timeout for running the browser to get its version.
+const versionTimeout = 5 * time.Second
+
+// browserVersion returns the browser version, or an empty string if it
+// can't be determined.  On Windows, the browser does not print its version,
+// and it is determined by the versioned directory next to the executable.
+func browserVersion(path string) string {
+	if runtime.GOOS == "windows" {
+		return versionFromDir(filepath.Dir(path))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	return reVersion.FindString(string(out))
+}
+
+// versionFromDir returns the version by the name of the versioned
+// subdirectory in dir, i.e. "Application\130.0.6723.58".
+func versionFromDir(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if e.IsDir() && reVersion.MatchString(e.Name()) && reVersion.FindString(e.Name()) == e.Name() {
+			return e.Name()
+		}
+	}
+	return ""
+}
+
+// desktopDirs returns the directories that contain the desktop entries.
+func desktopDirs() []string {
+	dirs := []string{
+		"/usr/share/applications",
+		"/usr/local/share/applications",
+		"/var/lib/flatpak/exports/share/applications",
+		"/var/lib/snapd/desktop/applications",
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs,
+			filepath.Join(home, ".local", "share", "applications"),
+			filepath.Join(home, ".local", "share", "flatpak", "exports", "share", "applications"),
+		)
+	}
+	return dirs
+}
+
+// desktopCandidates returns the Chromium-family browsers found in the
+// desktop entries in dirs.
+func desktopCandidates(dirs []string) []candidate {
+	var list []candidate
+	for _, dir := range dirs {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.desktop"))
+		if err != nil {
+			continue
+		}
+		for _, m := range matches {
+			f, err := os.Open(m)
+			if err != nil {
+				continue
+			}
+			exe := parseDesktopEntry(f)
+			f.Close()
+			if exe == "" || !isChromiumFamily(filepath.Base(exe)) {
+				continue
+			}
+			list = append(list, candidate{Name: browserName(exe), Path: exe})
+		}
+	}
+	return list
+}
+
+// parseDesktopEntry returns the executable of the desktop entry.  Only the
+// [Desktop Entry] group is considered.
+func parseDesktopEntry(r io.Reader) (exe string) {
+	var inEntry bool
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "[") {
+			inEntry = line == "[Desktop Entry]"
+			continue
+		}
+		if !inEntry {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok && strings.TrimSpace(k) == "Exec" {
+			return execCommand(strings.TrimSpace(v))
+		}
+	}
+	return ""
+}
+
+// flatpakExports is the directory with the flatpak application launchers.
+const flatpakExports = "/var/lib/flatpak/exports/bin"
+
+// execCommand returns the executable from the Exec key value of the desktop
+// entry.  Flatpak applications are resolved to the exported launcher.
+func execCommand(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		if end := strings.Index(s[1:], `"`); end >= 0 {
+			return s[1 : end+1]
+		}
+	}
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+	switch filepath.Base(fields[0]) {
+	case "env":
+		// env VAR=value /path/to/exe
+		for _, f := range fields[1:] {
+			if !strings.Contains(f, "=") {
+				return f
+			}
+		}
+		return ""
+	case "flatpak":
+		// flatpak run --branch=stable --command=brave com.brave.Browser
+		for _, f := range fields[1:] {
+			if f != "run" && !strings.HasPrefix(f, "-") && !strings.HasPrefix(f, "@@") {
+				return filepath.Join(flatpakExports, f)
+			}
+		}
+		return ""
+	}
+	return fields[0]
+}
+
+// chromiumFamily are the substrings that identify the Chromium-family
+// browsers.
+var chromiumFamily = []string{"chrome", "chromium", "brave", "edge", "vivaldi", "opera"}
+
+func isChromiumFamily(s string) bool {
+	s = strings.ToLower(s)
+	if strings.Contains(s, "remote-desktop") {
+		return false
+	}
+	for _, f := range chromiumFamily {
+		if strings.Contains(s, f) {
+			return true
+		}
+	}
+	return false
+}
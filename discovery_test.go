@@ -0,0 +1,177 @@
+package slackauth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_execCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		exec string
+		want string
+	}{
+		{"plain", "/usr/bin/google-chrome-stable %U", "/usr/bin/google-chrome-stable"},
+		{"quoted", `"/opt/My Browser/chrome" --incognito`, "/opt/My Browser/chrome"},
+		{"env", "env BAMF_DESKTOP_FILE_HINT=/x.desktop /snap/bin/chromium %U", "/snap/bin/chromium"},
+		{"flatpak", "/usr/bin/flatpak run --branch=stable --arch=x86_64 --command=brave --file-forwarding com.brave.Browser @@u %U @@", flatpakExports + "/com.brave.Browser"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, filepath.FromSlash(tt.want), filepath.FromSlash(execCommand(tt.exec)))
+		})
+	}
+}
+
+func Test_parseDesktopEntry(t *testing.T) {
+	const entry = `[Desktop Entry]
+Version=1.0
+Name=Vivaldi
+Exec=/usr/bin/vivaldi-stable %U
+
+[Desktop Action new-private-window]
+Exec=/usr/bin/vivaldi-stable --incognito
+`
+	assert.Equal(t, "/usr/bin/vivaldi-stable", parseDesktopEntry(strings.NewReader(entry)))
+	assert.Empty(t, parseDesktopEntry(strings.NewReader("[Desktop Action x]\nExec=/usr/bin/chrome\n")))
+}
+
+func Test_desktopCandidates(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"vivaldi.desktop": "[Desktop Entry]\nExec=/usr/bin/vivaldi-stable %U\n",
+		"crd.desktop":     "[Desktop Entry]\nExec=/opt/google/chrome-remote-desktop/chrome-remote-desktop --start\n",
+		"firefox.desktop": "[Desktop Entry]\nExec=firefox %u\n",
+		"not-desktop.txt": "[Desktop Entry]\nExec=/usr/bin/chromium\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	got := desktopCandidates([]string{dir, filepath.Join(dir, "missing")})
+	assert.Equal(t, []candidate{{bVivaldi, "/usr/bin/vivaldi-stable"}}, got)
+}
+
+func Test_detectChannel(t *testing.T) {
+	tests := []struct {
+		path string
+		want Channel
+	}{
+		{"/usr/bin/google-chrome-stable", ChannelStable},
+		{"/usr/bin/google-chrome-beta", ChannelBeta},
+		{"/usr/bin/google-chrome-unstable", ChannelDev},
+		{"/usr/bin/microsoft-edge-dev", ChannelDev},
+		{"/usr/bin/brave-browser-nightly", ChannelCanary},
+		{`C:\Users\me\AppData\Local\Google\Chrome SxS\Application\chrome.exe`, ChannelCanary},
+		{"/var/lib/flatpak/exports/bin/com.brave.Browser", ChannelFlatpak},
+		{"/snap/bin/chromium", ChannelSnap},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, detectChannel(tt.path))
+		})
+	}
+}
+
+func Test_versionFromDir(t *testing.T) {
+	dir := t.TempDir()
+	mkfiles(t, dir, "chrome.exe", "130.0.6723.58/chrome.dll", "SetupMetrics/x", "1.2.3/x")
+	assert.Equal(t, "130.0.6723.58", versionFromDir(dir))
+	assert.Empty(t, versionFromDir(filepath.Join(dir, "SetupMetrics")))
+}
+
+func Test_candidates(t *testing.T) {
+	registeredMu.Lock()
+	saved := registered
+	registered = nil
+	registeredMu.Unlock()
+	t.Cleanup(func() {
+		registeredMu.Lock()
+		registered = saved
+		registeredMu.Unlock()
+	})
+
+	t.Setenv(EnvBrowser, "/opt/vivaldi/vivaldi")
+	RegisterBrowser("Thorium", "/opt/thorium/thorium")
+
+	got := candidates()
+	require.GreaterOrEqual(t, len(got), 2)
+	assert.Equal(t, candidate{bVivaldi, "/opt/vivaldi/vivaldi"}, got[0])
+	assert.Equal(t, candidate{"Thorium", "/opt/thorium/thorium"}, got[1])
+}
+
+func Test_discover_env(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "chromium")
+	require.NoError(t, os.WriteFile(bin, []byte("#!/bin/sh\necho Chromium 130.0.6723.58 snap\n"), 0o755))
+	t.Setenv(EnvBrowser, bin)
+
+	found, ok := discover()
+	require.True(t, ok)
+	assert.Equal(t, bin, found[0].Path)
+	assert.Equal(t, bChromium, found[0].Name)
+	assert.Equal(t, ChannelStable, found[0].Channel)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, "130.0.6723.58", found[0].Version)
+	}
+}
+
+func Test_checkEnvBrowser(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "chromium")
+	require.NoError(t, os.WriteFile(bin, nil, 0o755))
+
+	t.Setenv(EnvBrowser, "")
+	assert.NoError(t, checkEnvBrowser())
+	t.Setenv(EnvBrowser, bin)
+	assert.NoError(t, checkEnvBrowser())
+
+	t.Setenv(EnvBrowser, filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, checkEnvBrowser(), ErrEnvBrowser)
+	_, err := ListBrowsers()
+	assert.ErrorIs(t, err, ErrEnvBrowser)
+	_, err = New("acme", WithSkipWorkspaceCheck())
+	assert.ErrorIs(t, err, ErrEnvBrowser, "must not fall back to another browser")
+	c, err := New("acme", WithSkipWorkspaceCheck(), WithRemoteDebuggingPort(9222))
+	require.NoError(t, err, "the local browser is not used when attached")
+	require.NoError(t, c.Close())
+}
+
+func Test_discover_parallel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("versions are read from the directory on windows")
+	}
+	registeredMu.Lock()
+	saved := registered
+	registered = nil
+	registeredMu.Unlock()
+	t.Cleanup(func() {
+		registeredMu.Lock()
+		registered = saved
+		registeredMu.Unlock()
+	})
+	t.Setenv(EnvBrowser, "")
+
+	const n = 4
+	dir := t.TempDir()
+	for i := range n {
+		bin := filepath.Join(dir, fmt.Sprintf("chromium-%d", i))
+		require.NoError(t, os.WriteFile(bin, []byte("#!/bin/sh\nsleep 1\necho Chromium 130.0.6723.5"+strconv.Itoa(i)+"\n"), 0o755))
+		RegisterBrowser(bChromium, bin)
+	}
+
+	start := time.Now()
+	found, ok := discover()
+	require.True(t, ok)
+	assert.Less(t, time.Since(start), n*time.Second, "versions must be queried in parallel")
+	for i := range n {
+		assert.Equal(t, "130.0.6723.5"+strconv.Itoa(i), found[i].Version)
+	}
+}
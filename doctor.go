@@ -0,0 +1,397 @@
+package slackauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"runtime/trace"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+const (
+	doctorTimeout  = 30 * time.Second // timeout for each of the browser checks
+	elementTimeout = 5 * time.Second  // time to wait for the element to appear
+)
+
+// CheckStatus is the outcome of the diagnostic check.
+type CheckStatus int
+
+const (
+	CheckPass CheckStatus = iota // check passed
+	CheckFail                    // check failed
+	CheckSkip                    // check was not performed
+)
+
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckPass:
+		return "PASS"
+	case CheckFail:
+		return "FAIL"
+	case CheckSkip:
+		return "SKIP"
+	default:
+		return fmt.Sprintf("CheckStatus(%d)", int(s))
+	}
+}
+
+// Check is the result of a single diagnostic check.
+type Check struct {
+	Name     string
+	Status   CheckStatus
+	Detail   string // what was found, i.e. the browser version
+	Err      error  // failure reason
+	Duration time.Duration
+}
+
+// Report is the diagnostic report, see [Doctor].
+type Report struct {
+	Workspace string
+	OS        string
+	Arch      string
+	GoVersion string
+	Version   string // slackauth module version
+	Leakless  bool   // true if the browser is started with leakless
+	Checks    []Check
+}
+
+// OK returns true if none of the checks failed.
+func (r *Report) OK() bool {
+	for _, ch := range r.Checks {
+		if ch.Status == CheckFail {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTo writes the human-readable report to w, suitable for pasting into
+// the bug report.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	tw := tabwriter.NewWriter(cw, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "slackauth:\t%s\n", r.Version)
+	fmt.Fprintf(tw, "go:\t%s %s/%s\n", r.GoVersion, r.OS, r.Arch)
+	fmt.Fprintf(tw, "leakless:\t%t\n", r.Leakless)
+	fmt.Fprintf(tw, "workspace:\t%s\n", r.Workspace)
+	fmt.Fprintln(tw)
+	for _, ch := range r.Checks {
+		detail := ch.Detail
+		if ch.Err != nil {
+			if detail != "" {
+				detail += ": "
+			}
+			detail += ch.Err.Error()
+		}
+		fmt.Fprintf(tw, "[%s]\t%s\t%s\t%s\n", ch.Status, ch.Name, ch.Duration.Round(time.Millisecond), detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, cw.err
+}
+
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
+
+// run runs the check fn and records the outcome.  It returns true if the
+// check passed.
+func (r *Report) run(name string, fn func() (detail string, err error)) bool {
+	start := time.Now()
+	detail, err := fn()
+	ch := Check{Name: name, Status: CheckPass, Detail: detail, Err: err, Duration: time.Since(start)}
+	if err != nil {
+		ch.Status = CheckFail
+	}
+	r.Checks = append(r.Checks, ch)
+	return err == nil
+}
+
+// skip records the skipped check.
+func (r *Report) skip(name, reason string) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: CheckSkip, Detail: reason})
+}
+
+func newReport(workspace string) *Report {
+	return &Report{
+		Workspace: workspace,
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		GoVersion: runtime.Version(),
+		Version:   moduleVersion(),
+		Leakless:  isLeaklessEnabled,
+	}
+}
+
+// moduleVersion returns the version of the slackauth module from the build
+// information.
+func moduleVersion() string {
+	const path = "github.com/rusq/slackauth"
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if bi.Main.Path == path {
+		return bi.Main.Version
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path == path {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+const (
+	checkClient    = "client"
+	checkDiscovery = "browser discovery"
+	checkWorkspace = "workspace"
+	checkLaunch    = "browser launch"
+	checkSignIn    = "sign in page"
+)
+
+// Doctor diagnoses the environment and the login page: it checks that the
+// client can be created with the options, that the browser can be discovered
+// and launched headless, that the workspace resolves, that the sign in page
+// loads, and that the login page elements can be found with the selectors.
+// Unlike [NewContext], it reports the workspace check failure in the report
+// instead of returning it.
+func Doctor(ctx context.Context, workspace string, opt ...Option) *Report {
+	ctx, task := trace.NewTask(ctx, "Doctor")
+	defer task.End()
+
+	r := newReport(workspace)
+	var c *Client
+	if !r.run(checkClient, func() (string, error) {
+		var err error
+		c, err = NewContext(ctx, workspace, append(opt, WithSkipWorkspaceCheck())...)
+		return "", err
+	}) {
+		return r
+	}
+	defer c.Close()
+	c.doctor(ctx, r)
+	return r
+}
+
+// Doctor runs the diagnostics with the client options, see [Doctor].  The
+// browser that it starts is closed when it returns.
+func (c *Client) Doctor(ctx context.Context) *Report {
+	ctx, task := trace.NewTask(ctx, "Doctor")
+	defer task.End()
+
+	r := newReport(c.wspURL)
+	c.doctor(ctx, r)
+	return r
+}
+
+func (c *Client) doctor(ctx context.Context, r *Report) {
+	r.Workspace = c.wspURL
+	r.run(checkDiscovery, c.checkDiscovery)
+	r.run(checkWorkspace, func() (string, error) {
+		if err := checkWorkspaceURL(ctx, c.opts.httpClient(), c.wspURL); err != nil {
+			return "", err
+		}
+		return c.wspURL, nil
+	})
+
+	// the browser, that the checks below use, is closed when the doctor
+	// finishes, and its context is cancelled after that.
+	bctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	defer c.closeFrom(len(c.cleanupFn))
+
+	var browser *rod.Browser
+	if !r.run(checkLaunch, func() (string, error) {
+		// the launch timeout cancels the browser context, so that it
+		// does not outlive the failed launch.
+		timer := time.AfterFunc(doctorTimeout, func() {
+			stop(fmt.Errorf("browser did not start in %s", doctorTimeout))
+		})
+		var err error
+		browser, err = c.startPuppet(bctx, true)
+		if !timer.Stop() {
+			return "", context.Cause(bctx)
+		}
+		if err != nil {
+			return "", err
+		}
+		ver, err := proto.BrowserGetVersion{}.Call(browser)
+		if err != nil {
+			return "", ErrBrowser{Err: err, FailedTo: "get browser version"}
+		}
+		return ver.Product, nil
+	}) {
+		r.skip(checkSignIn, "browser is not running")
+		c.skipSelectors(r, "browser is not running")
+		return
+	}
+
+	var page *rod.Page
+	if !r.run(checkSignIn, func() (string, error) {
+		var err error
+		page, _, err = c.openSlackAuthTab(bctx, browser.Timeout(doctorTimeout))
+		if err != nil {
+			return "", err
+		}
+		info, err := page.Info()
+		if err != nil {
+			return "", ErrBrowser{Err: err, FailedTo: "get page info"}
+		}
+		return fmt.Sprintf("%q at %s", info.Title, info.URL), nil
+	}) {
+		c.skipSelectors(r, "sign in page did not load")
+		return
+	}
+	c.checkSelectors(r, page.Context(bctx))
+}
+
+// checkDiscovery reports the browser that will be used.
+func (c *Client) checkDiscovery() (string, error) {
+	if c.opts.isAttached() {
+		return "attached to the running browser", nil
+	}
+	if path, ok := c.opts.browserPath(); ok {
+		name, version := browserName(path), browserVersion(path)
+		if found, ok := discover(); ok {
+			for _, b := range found {
+				if b.Path == path {
+					name, version = b.Name, b.Version
+					break
+				}
+			}
+		}
+		return fmt.Sprintf("%s %s (%s)", name, version, path), nil
+	}
+	info, err := c.opts.bundled.Info()
+	if err != nil {
+		return "", fmt.Errorf("bundled browser: %w", err)
+	}
+	if !info.Installed {
+		if _, err := c.opts.bundled.archiveSum(); err != nil {
+			return fmt.Sprintf("no browsers found, bundled revision %d can't be downloaded", info.Revision), err
+		}
+		return fmt.Sprintf("no browsers found, bundled revision %d will be downloaded to %s", info.Revision, info.Dir), nil
+	}
+	if err := c.opts.bundled.Verify(); err != nil && !errors.Is(err, ErrNotInstalled) {
+		return info.Path, err
+	}
+	return fmt.Sprintf("bundled revision %d (%s)", info.Revision, info.Path), nil
+}
+
+// selectorCheck is the selector to check.  Selectors that are not on the sign
+// in page, i.e. the error messages, are only checked for validity.
+type selectorCheck struct {
+	name   string
+	sel    string
+	signIn bool // shown on the sign in page
+}
+
+func (c *Client) selectorChecks() []selectorCheck {
+	s := c.opts.selectors
+	return []selectorCheck{
+		{"email", s.Email, true},
+		{"password", s.Password, true},
+		{"password_login", s.PasswordLogin, false},
+		{"any_error", s.AnyError, false},
+		{"password_error", s.PasswordError, false},
+		{"sign_in_alert_text", s.SignInAlertText, false},
+		{"unknown_browser", s.UnknownBrowser, false},
+		{"digit_n", fmt.Sprintf(s.DigitN, 1), false},
+		{"code_error", s.CodeError, false},
+		{"redirect", s.Redirect, false},
+	}
+}
+
+func (c *Client) skipSelectors(r *Report, reason string) {
+	for _, sc := range c.selectorChecks() {
+		r.skip("selector "+sc.name, reason)
+	}
+}
+
+// checkSelectors checks that the sign in page elements can be found with the
+// selectors, switching to the password login if necessary, the same way
+// doAutoLogin does, and that the rest of the selectors are valid.
+func (c *Client) checkSelectors(r *Report, page *rod.Page) {
+	sel := c.opts.selectors
+	emailPage, pwdForm := false, true
+	if has, _, err := page.Has(sel.Password); err == nil && !has {
+		emailPage = true
+		// the password form is behind the password_login link.
+		pwdForm = r.run("selector password_login", func() (string, error) {
+			el, err := findElement(page, sel.PasswordLogin)
+			if err != nil {
+				return sel.PasswordLogin, err
+			}
+			if err := el.Click(proto.InputMouseButtonLeft, 1); err != nil {
+				return sel.PasswordLogin, ErrBrowser{Err: err, FailedTo: "click password login link"}
+			}
+			return sel.PasswordLogin, nil
+		})
+	}
+	for _, sc := range c.selectorChecks() {
+		name := "selector " + sc.name
+		switch {
+		case sc.name == "password_login" && emailPage:
+			// checked above.
+		case sc.signIn && !pwdForm:
+			r.skip(name, "password form is not shown")
+		case sc.signIn:
+			r.run(name, func() (string, error) {
+				_, err := findElement(page, sc.sel)
+				return sc.sel, err
+			})
+		default:
+			if err := validSelector(page, sc.sel); err != nil {
+				r.run(name, func() (string, error) { return sc.sel, err })
+				continue
+			}
+			reason := "valid, shown after the sign in attempt"
+			if sc.name == "password_login" {
+				reason = "valid, not needed on the password form"
+			}
+			r.skip(name, sc.sel+": "+reason)
+		}
+	}
+}
+
+// findElement waits for the element to appear on the page.
+func findElement(page *rod.Page, sel string) (*rod.Element, error) {
+	el, err := page.Timeout(elementTimeout).Element(sel)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, errors.New("element not found")
+	}
+	return el, err
+}
+
+// validSelector checks that sel is a valid CSS selector.
+func validSelector(page *rod.Page, sel string) error {
+	res, err := page.Eval(`(s) => { try { document.querySelector(s); return ""; } catch (e) { return e.message; } }`, sel)
+	if err != nil {
+		return ErrBrowser{Err: err, FailedTo: "validate selector"}
+	}
+	if msg := res.Value.Str(); msg != "" {
+		return fmt.Errorf("invalid selector: %s", msg)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package slackauth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rusq/slackauth/slackauthtest"
+)
+
+func TestReport_OK(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []Check
+		want   bool
+	}{
+		{"empty", nil, true},
+		{"pass and skip", []Check{{Status: CheckPass}, {Status: CheckSkip}}, true},
+		{"fail", []Check{{Status: CheckPass}, {Status: CheckFail}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Report{Checks: tt.checks}
+			assert.Equal(t, tt.want, r.OK())
+		})
+	}
+}
+
+func TestReport_WriteTo(t *testing.T) {
+	r := &Report{
+		Workspace: "https://acme.slack.com/",
+		OS:        "linux",
+		Arch:      "amd64",
+		GoVersion: "go1.22.0",
+		Version:   "v1.2.3",
+		Leakless:  true,
+		Checks: []Check{
+			{Name: checkDiscovery, Status: CheckPass, Detail: "Chromium 130.0 (/usr/bin/chromium)", Duration: 12 * time.Millisecond},
+			{Name: checkLaunch, Status: CheckFail, Err: errors.New("exec failed")},
+			{Name: "selector redirect", Status: CheckSkip, Detail: "browser is not running"},
+		},
+	}
+	var sb strings.Builder
+	n, err := r.WriteTo(&sb)
+	require.NoError(t, err)
+	assert.Equal(t, int64(sb.Len()), n)
+	got := sb.String()
+	for _, want := range []string{
+		"slackauth:  v1.2.3\n",
+		"go:         go1.22.0 linux/amd64\n",
+		"workspace:  https://acme.slack.com/\n",
+		"[PASS]  browser discovery  12ms  Chromium 130.0 (/usr/bin/chromium)\n",
+		"[FAIL]  browser launch     0s    exec failed\n",
+		"[SKIP]  selector redirect  0s    browser is not running\n",
+	} {
+		assert.Contains(t, got, want)
+	}
+}
+
+func TestCheckStatus_String(t *testing.T) {
+	assert.Equal(t, "PASS", CheckPass.String())
+	assert.Equal(t, "FAIL", CheckFail.String())
+	assert.Equal(t, "SKIP", CheckSkip.String())
+	assert.Equal(t, "CheckStatus(42)", CheckStatus(42).String())
+}
+
+func TestDoctor_badWorkspace(t *testing.T) {
+	r := Doctor(context.Background(), "not a workspace!")
+	require.Len(t, r.Checks, 1)
+	assert.Equal(t, checkClient, r.Checks[0].Name)
+	assert.Equal(t, CheckFail, r.Checks[0].Status)
+	assert.False(t, r.OK())
+}
+
+func TestDoctor_noBrowser(t *testing.T) {
+	srv := slackauthtest.NewServer()
+	defer srv.Close()
+	// the bundled revision without the pinned checksum can't be downloaded.
+	r := Doctor(context.Background(), srv.URL, WithBundledBrowser(), WithBundledDir(t.TempDir()), WithBundledRevision(1))
+	assert.Equal(t, CheckPass, checkStatus(t, r, checkClient))
+	assert.Equal(t, CheckFail, checkStatus(t, r, checkDiscovery))
+	assert.Equal(t, CheckPass, checkStatus(t, r, checkWorkspace))
+	assert.Equal(t, CheckFail, checkStatus(t, r, checkLaunch))
+	assert.Equal(t, CheckSkip, checkStatus(t, r, checkSignIn))
+	assert.Equal(t, CheckSkip, checkStatus(t, r, "selector email"))
+	assert.False(t, r.OK())
+}
+
+func TestClient_checkDiscovery_bundled(t *testing.T) {
+	srv := slackauthtest.NewServer()
+	defer srv.Close()
+	opts := []Option{WithBundledBrowser(), WithBundledDir(t.TempDir()), WithBundledRevision(1)}
+
+	c, err := New(srv.URL, opts...)
+	require.NoError(t, err)
+	defer c.Close()
+	_, err = c.checkDiscovery()
+	assert.ErrorIs(t, err, ErrNoChecksum)
+
+	c, err = New(srv.URL, append(opts, WithBundledArchiveSum(strings.Repeat("0", 64)))...)
+	require.NoError(t, err)
+	defer c.Close()
+	detail, err := c.checkDiscovery()
+	require.NoError(t, err)
+	assert.Contains(t, detail, "bundled revision 1 will be downloaded")
+}
+
+func TestClient_Doctor_closesBrowser(t *testing.T) {
+	srv := slackauthtest.NewServer()
+	defer srv.Close()
+	c, err := New(srv.URL, WithSkipWorkspaceCheck(), WithLocalBrowser(launchableBrowser(t)))
+	require.NoError(t, err)
+	defer c.Close()
+
+	r := c.Doctor(context.Background())
+	assert.Equal(t, CheckFail, checkStatus(t, r, checkLaunch), "fake browser can't be connected to")
+	assert.Empty(t, c.cleanupFn, "browser must be closed when the doctor finishes")
+}
+
+// checkStatus returns the status of the named check.
+func checkStatus(t *testing.T, r *Report, name string) CheckStatus {
+	t.Helper()
+	return findCheck(t, r, name).Status
+}
+
+// findCheck returns the named check.
+func findCheck(t *testing.T, r *Report, name string) Check {
+	t.Helper()
+	for _, ch := range r.Checks {
+		if ch.Name == name {
+			return ch
+		}
+	}
+	t.Fatalf("check %q not found", name)
+	return Check{}
+}
+
+func TestE2E_Doctor(t *testing.T) {
+	tests := []struct {
+		name    string
+		srvOpts []slackauthtest.Option
+		opts    []Option
+		want    map[string]CheckStatus
+		wantOK  bool
+	}{
+		{
+			name:   "password page",
+			wantOK: true,
+			want: map[string]CheckStatus{
+				checkLaunch:               CheckPass,
+				checkSignIn:               CheckPass,
+				"selector email":          CheckPass,
+				"selector password":       CheckPass,
+				"selector password_login": CheckSkip,
+				"selector redirect":       CheckSkip,
+			},
+		},
+		{
+			name:    "email page",
+			srvOpts: []slackauthtest.Option{slackauthtest.WithEmailLogin()},
+			wantOK:  true,
+			want: map[string]CheckStatus{
+				"selector password_login": CheckPass,
+				"selector password":       CheckPass,
+			},
+		},
+		{
+			name: "missing element",
+			opts: []Option{WithSelectors(Selectors{Email: "#login_email"})},
+			want: map[string]CheckStatus{
+				"selector email":    CheckFail,
+				"selector password": CheckPass,
+			},
+		},
+		{
+			name: "invalid selector",
+			opts: []Option{WithSelectors(Selectors{Redirect: "[data-qa="})},
+			want: map[string]CheckStatus{
+				"selector redirect": CheckFail,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := slackauthtest.NewServer(tt.srvOpts...)
+			defer srv.Close()
+			c := e2eClient(t, srv, tt.opts...)
+
+			r := c.Doctor(e2eContext(t))
+			for name, want := range tt.want {
+				assert.Equal(t, want, checkStatus(t, r, name), name)
+			}
+			if !assert.Equal(t, tt.wantOK, r.OK()) {
+				var sb strings.Builder
+				r.WriteTo(&sb)
+				t.Log(sb.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package slackauth
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rusq/slackauth/slackauthtest"
+)
+
+const e2eTimeout = 60 * time.Second
+
+// e2eClient returns the client for the fake Slack server.  It skips the test
+// in short mode, or if there are no browsers installed.
+func e2eClient(t *testing.T, srv *slackauthtest.Server, opts ...Option) *Client {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	if _, ok := lookPath(); !ok {
+		t.Skip("no browsers found")
+	}
+	opts = append([]Option{WithSkipWorkspaceCheck(), WithAutologinTimeout(e2eTimeout)}, opts...)
+	c, err := NewContext(context.Background(), srv.URL, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func e2eContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), e2eTimeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// assertSession checks that the session cookie is among cookies.
+func assertSession(t *testing.T, srv *slackauthtest.Server, cookies []*http.Cookie) {
+	t.Helper()
+	want := srv.SessionCookie()
+	for _, c := range cookies {
+		if c.Name == want.Name && c.Value == want.Value {
+			return
+		}
+	}
+	t.Errorf("session cookie %q not found", want.Name)
+}
+
+func TestE2E_Headless(t *testing.T) {
+	tests := []struct {
+		name     string
+		srvOpts  []slackauthtest.Option
+		password string
+		code     int
+		wantErr  error
+	}{
+		{
+			name:     "success",
+			password: slackauthtest.DefaultPassword,
+		},
+		{
+			name:     "email login page",
+			srvOpts:  []slackauthtest.Option{slackauthtest.WithEmailLogin()},
+			password: slackauthtest.DefaultPassword,
+		},
+		{
+			name:     "invalid credentials",
+			password: "wrong",
+			wantErr:  ErrInvalidCredentials,
+		},
+		{
+			name:     "login error",
+			srvOpts:  []slackauthtest.Option{slackauthtest.WithLoginError("Your account has been locked")},
+			password: slackauthtest.DefaultPassword,
+			wantErr:  ErrLoginError,
+		},
+		{
+			name:     "challenge code",
+			srvOpts:  []slackauthtest.Option{slackauthtest.WithChallengeCode(123456)},
+			password: slackauthtest.DefaultPassword,
+			code:     123456,
+		},
+		{
+			name:     "invalid challenge code",
+			srvOpts:  []slackauthtest.Option{slackauthtest.WithChallengeCode(123456)},
+			password: slackauthtest.DefaultPassword,
+			code:     654321,
+			wantErr:  ErrInvalidChallengeCode,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := slackauthtest.NewServer(tt.srvOpts...)
+			defer srv.Close()
+			c := e2eClient(t, srv, WithChallengeFunc(func(string) (int, error) {
+				return tt.code, nil
+			}))
+
+			token, cookies, err := c.Headless(e2eContext(t), slackauthtest.DefaultEmail, tt.password)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, slackauthtest.DefaultToken, token)
+			assertSession(t, srv, cookies)
+		})
+	}
+}
+
+func TestE2E_Manual(t *testing.T) {
+	srv := slackauthtest.NewServer()
+	defer srv.Close()
+	// the session cookie stands in for the user signing in, the browser is
+	// started headless, as there may be no display.
+	c := e2eClient(t, srv,
+		WithCookie(srv.SessionCookie()),
+		WithBrowserFlags(map[string]string{"headless": ""}),
+	)
+
+	token, cookies, err := c.Manual(e2eContext(t))
+	require.NoError(t, err)
+	assert.Equal(t, slackauthtest.DefaultToken, token)
+	assertSession(t, srv, cookies)
+}
+
+func TestE2E_linkAuth(t *testing.T) {
+	srv := slackauthtest.NewServer()
+	defer srv.Close()
+
+	t.Run("valid link", func(t *testing.T) {
+		c := e2eClient(t, srv, WithBrowserFlags(map[string]string{"headless": ""}))
+		token, cookies, err := c.linkAuth(e2eContext(t), srv.LoginLink())
+		require.NoError(t, err)
+		assert.Equal(t, slackauthtest.DefaultToken, token)
+		assertSession(t, srv, cookies)
+	})
+	t.Run("expired link", func(t *testing.T) {
+		c := e2eClient(t, srv, WithBrowserFlags(map[string]string{"headless": ""}))
+		_, _, err := c.linkAuth(e2eContext(t), srv.ExpiredLink())
+		assert.ErrorIs(t, err, ErrLinkExpired)
+	})
+}
+
+func TestNewContext_fakeServer(t *testing.T) {
+	srv := slackauthtest.NewServer()
+	defer srv.Close()
+	c, err := NewContext(context.Background(), srv.URL)
+	require.NoError(t, err, "workspace check must pass")
+	c.Close()
+
+	srv.Close()
+	_, err = NewContext(context.Background(), srv.URL)
+	var ec ErrWorkspaceCheck
+	assert.ErrorAs(t, err, &ec)
+}
+
+func TestClient_RedeemLink_fakeServer(t *testing.T) {
+	srv := slackauthtest.NewServer()
+	defer srv.Close()
+	c, err := NewContext(context.Background(), srv.URL, WithSkipWorkspaceCheck())
+	require.NoError(t, err)
+	defer c.Close()
+
+	token, cookies, err := c.RedeemLink(context.Background(), srv.LoginLink())
+	require.NoError(t, err)
+	assert.Equal(t, slackauthtest.DefaultToken, token)
+	assertSession(t, srv, cookies)
+
+	_, _, err = c.RedeemLink(context.Background(), srv.ExpiredLink())
+	assert.ErrorIs(t, err, ErrLinkExpired)
+
+	srv.Close()
+	_, _, err = c.RedeemLink(context.Background(), srv.LoginLink())
+	var uerr *url.Error
+	assert.ErrorAs(t, err, &uerr)
+	assert.NotContains(t, err.Error(), "4567890123", "the link must be redacted")
+	assert.Contains(t, err.Error(), loginLinkRedacted)
+}
@@ -0,0 +1,286 @@
+package slackauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// Fingerprint is the set of browser properties, that Slack may use to
+// recognise the browser.  If any of them changes between logins, Slack is
+// likely to challenge the user with the confirmation code.
+type Fingerprint struct {
+	UserAgent         string                            `json:"user_agent"`
+	Platform          string                            `json:"platform"`                      // navigator.platform
+	UserAgentMetadata *proto.EmulationUserAgentMetadata `json:"user_agent_metadata,omitempty"` // client hints for the UserAgent
+	AcceptLanguage    string                            `json:"accept_language"`               // i.e. "en-US,en;q=0.9"
+	Locale            string                            `json:"locale"`                        // i.e. "en-US"
+	Timezone          string                            `json:"timezone"`                      // IANA timezone, i.e. "Pacific/Auckland"
+	Width             int                               `json:"width"`                         // viewport width
+	Height            int                               `json:"height"`                        // viewport height
+	DeviceScaleFactor float64                           `json:"device_scale_factor"`
+}
+
+// viewport is the screen size with the scale factor.
+type viewport struct {
+	w, h  int
+	scale float64
+}
+
+// commonViewports are the common desktop viewports, by OS.  Only Macs have
+// the Retina displays with the scale factor of 2, the Windows laptops are
+// often scaled by 125% or 150%.
+var commonViewports = map[string][]viewport{
+	"darwin": {
+		{1440, 900, 2},
+		{1512, 982, 2},
+		{1728, 1117, 2},
+		{1280, 800, 2},
+		{1536, 960, 2},
+		{1920, 1080, 1},
+		{2560, 1440, 1},
+	},
+	"linux": {
+		{1920, 1080, 1},
+		{1366, 768, 1},
+		{1600, 900, 1},
+		{1680, 1050, 1},
+		{1920, 1200, 1},
+		{2560, 1440, 1},
+	},
+	"windows": {
+		{1920, 1080, 1},
+		{1536, 864, 1.25},
+		{1280, 720, 1.5},
+		{1366, 768, 1},
+		{1600, 900, 1},
+		{1440, 900, 1},
+		{2560, 1440, 1},
+	},
+}
+
+// viewports returns the common viewports for the OS.  Other unix-like
+// systems get the Linux ones.
+func viewports(goos string) []viewport {
+	if vps, ok := commonViewports[goos]; ok {
+		return vps
+	}
+	return commonViewports["linux"]
+}
+
+// NewFingerprint generates a new realistic fingerprint for the current
+// platform.  The locale and timezone are taken from the environment.  The
+// user agent is left empty, it is set from the browser on the first login,
+// so that it matches the browser engine.
+func NewFingerprint() Fingerprint {
+	vps := viewports(runtime.GOOS)
+	vp := vps[rand.IntN(len(vps))]
+	locale := systemLocale()
+	return Fingerprint{
+		AcceptLanguage:    acceptLanguage(locale),
+		Locale:            locale,
+		Timezone:          systemTimezone(),
+		Width:             vp.w,
+		Height:            vp.h,
+		DeviceScaleFactor: vp.scale,
+	}
+}
+
+// LoadFingerprint loads the fingerprint from the file.
+func LoadFingerprint(path string) (Fingerprint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+	var fp Fingerprint
+	if err := json.Unmarshal(data, &fp); err != nil {
+		return Fingerprint{}, fmt.Errorf("invalid fingerprint file %s: %w", path, err)
+	}
+	return fp, nil
+}
+
+// Save saves the fingerprint to the file.
+func (fp Fingerprint) Save(path string) error {
+	data, err := json.MarshalIndent(fp, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// loadOrCreateFingerprint loads the fingerprint from the file, if it
+// exists, otherwise it generates the new one and saves it to the file.
+func loadOrCreateFingerprint(path string) (Fingerprint, error) {
+	fp, err := LoadFingerprint(path)
+	if err == nil {
+		return fp, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return Fingerprint{}, err
+	}
+	fp = NewFingerprint()
+	if err := fp.Save(path); err != nil {
+		return Fingerprint{}, err
+	}
+	return fp, nil
+}
+
+// emulation returns the locale, timezone and Accept-Language overrides,
+// merged with the fingerprint, if it's set.  The values set with the options
+// take precedence over the fingerprint ones.
+func (o options) emulation() Fingerprint {
+	var fp Fingerprint
+	if o.fingerprint != nil {
+		fp = *o.fingerprint
+	}
+	if o.locale != "" {
+		fp.Locale = o.locale
+		fp.AcceptLanguage = acceptLanguage(o.locale)
+	}
+	if o.acceptLanguage != "" {
+		fp.AcceptLanguage = o.acceptLanguage
+	}
+	if o.timezone != "" {
+		fp.Timezone = o.timezone
+	}
+	return fp
+}
+
+// emulationFlags sets the launcher flags and the environment, so that the
+// browser starts with the emulated locale and timezone.
+func emulationFlags(l *launcher.Launcher, fp Fingerprint) *launcher.Launcher {
+	if fp.Locale != "" {
+		l = l.Set("lang", fp.Locale)
+	}
+	if fp.AcceptLanguage != "" {
+		l = l.Set("accept-lang", fp.AcceptLanguage)
+	}
+	if fp.Timezone != "" {
+		l = l.Env(append(os.Environ(), "TZ="+fp.Timezone)...)
+	}
+	return l
+}
+
+// userAgentOverride returns the user agent override for the fingerprint.
+func (fp Fingerprint) userAgentOverride() *proto.NetworkSetUserAgentOverride {
+	return &proto.NetworkSetUserAgentOverride{
+		UserAgent:         fp.UserAgent,
+		AcceptLanguage:    fp.AcceptLanguage,
+		Platform:          fp.Platform,
+		UserAgentMetadata: fp.UserAgentMetadata,
+	}
+}
+
+// staleUserAgent returns true if the user agent of the fingerprint does not
+// match the browser user agent ua: it is not set, has no client hints, or
+// the browser major version has changed since, i.e. after the update.
+func (fp Fingerprint) staleUserAgent(ua *proto.NetworkSetUserAgentOverride) bool {
+	if fp.UserAgent == "" || fp.UserAgentMetadata == nil {
+		return true
+	}
+	major, _, _ := strings.Cut(fp.UserAgentMetadata.FullVersion, ".")
+	newMajor, _, _ := strings.Cut(ua.UserAgentMetadata.FullVersion, ".")
+	return major != newMajor
+}
+
+// emulate applies the viewport, locale and timezone overrides of the
+// fingerprint to the page.
+func (fp Fingerprint) emulate(pg proto.Client) error {
+	if fp.Width > 0 && fp.Height > 0 {
+		if err := (proto.EmulationSetDeviceMetricsOverride{
+			Width:             fp.Width,
+			Height:            fp.Height,
+			DeviceScaleFactor: fp.DeviceScaleFactor,
+			ScreenWidth:       &fp.Width,
+			ScreenHeight:      &fp.Height,
+		}).Call(pg); err != nil {
+			return fmt.Errorf("viewport: %w", err)
+		}
+	}
+	if fp.Locale != "" {
+		if err := (proto.EmulationSetLocaleOverride{Locale: fp.Locale}).Call(pg); err != nil {
+			return fmt.Errorf("locale: %w", err)
+		}
+	}
+	if fp.Timezone != "" {
+		if err := (proto.EmulationSetTimezoneOverride{TimezoneID: fp.Timezone}).Call(pg); err != nil {
+			return fmt.Errorf("timezone: %w", err)
+		}
+	}
+	return nil
+}
+
+// systemLocale returns the locale of the environment in BCP 47 format, i.e.
+// "en-US".
+func systemLocale() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if l := posixToBCP47(os.Getenv(env)); l != "" {
+			return l
+		}
+	}
+	return "en-US"
+}
+
+// posixToBCP47 converts the POSIX locale (i.e. "en_NZ.UTF-8") to BCP 47
+// ("en-NZ").  It returns an empty string for the "C" and "POSIX" locales.
+func posixToBCP47(s string) string {
+	s, _, _ = strings.Cut(s, ".")
+	s, _, _ = strings.Cut(s, "@")
+	if s == "" || s == "C" || s == "POSIX" {
+		return ""
+	}
+	return strings.ReplaceAll(s, "_", "-")
+}
+
+// acceptLanguage returns the Accept-Language header value for the locale,
+// i.e. "de-DE,de;q=0.9,en;q=0.8" for "de-DE".
+func acceptLanguage(locale string) string {
+	lang, _, hasRegion := strings.Cut(locale, "-")
+	switch {
+	case lang == "en" && hasRegion:
+		return locale + ",en;q=0.9"
+	case lang == "en":
+		return locale
+	case hasRegion:
+		return locale + "," + lang + ";q=0.9,en;q=0.8"
+	default:
+		return locale + ",en;q=0.9"
+	}
+}
+
+// systemTimezone returns the IANA name of the local timezone.  If it can't be
+// determined, it returns the fixed offset zone of the current UTC offset,
+// i.e. "Etc/GMT-12" for UTC+12, or "UTC".
+func systemTimezone() string {
+	if tz := os.Getenv("TZ"); tz != "" && !strings.HasPrefix(tz, ":") {
+		return tz
+	}
+	if tz := localTimezone(); tz != "" {
+		return tz
+	}
+	_, offset := time.Now().Zone()
+	return offsetTimezone(offset)
+}
+
+// offsetTimezone returns the IANA fixed offset zone for the UTC offset in
+// seconds, or "UTC" if the offset is not in whole hours.  The sign of the
+// "Etc/GMT" zones is inverted.
+func offsetTimezone(offset int) string {
+	if offset == 0 || offset%3600 != 0 {
+		return "UTC"
+	}
+	return fmt.Sprintf("Etc/GMT%+d", -offset/3600)
+}
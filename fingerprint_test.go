@@ -0,0 +1,251 @@
+package slackauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/launcher/flags"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCDP is a proto.Client that records the calls.
+type fakeCDP struct {
+	calls  []string
+	params []interface{}
+	err    error
+}
+
+func (f *fakeCDP) Call(_ context.Context, _, method string, params interface{}) ([]byte, error) {
+	f.calls = append(f.calls, method)
+	f.params = append(f.params, params)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []byte("{}"), nil
+}
+
+func Test_loadOrCreateFingerprint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acc", "fingerprint.json")
+
+	first, err := loadOrCreateFingerprint(path)
+	require.NoError(t, err)
+	assert.FileExists(t, path)
+	assert.Empty(t, first.UserAgent, "user agent is set from the browser")
+	assert.NotZero(t, first.Width)
+
+	second, err := loadOrCreateFingerprint(path)
+	require.NoError(t, err)
+	assert.Equal(t, first, second, "fingerprint must be stable between runs")
+
+	require.NoError(t, os.WriteFile(path, []byte("garbage"), 0o600))
+	_, err = loadOrCreateFingerprint(path)
+	assert.Error(t, err)
+}
+
+func TestFingerprint_emulate(t *testing.T) {
+	fp := Fingerprint{Locale: "de-DE", Timezone: "Europe/Berlin", Width: 1366, Height: 768, DeviceScaleFactor: 1}
+	t.Run("all overrides", func(t *testing.T) {
+		var cdp fakeCDP
+		require.NoError(t, fp.emulate(&cdp))
+		assert.Equal(t, []string{
+			"Emulation.setDeviceMetricsOverride",
+			"Emulation.setLocaleOverride",
+			"Emulation.setTimezoneOverride",
+		}, cdp.calls)
+		data, err := json.Marshal(cdp.params[0])
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"width":1366`)
+	})
+	t.Run("empty fingerprint", func(t *testing.T) {
+		var cdp fakeCDP
+		require.NoError(t, Fingerprint{}.emulate(&cdp))
+		assert.Empty(t, cdp.calls)
+	})
+	t.Run("error", func(t *testing.T) {
+		cdp := fakeCDP{err: errors.New("boom")}
+		assert.Error(t, fp.emulate(&cdp))
+	})
+}
+
+func Test_acceptLanguage(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"en-US", "en-US,en;q=0.9"},
+		{"en", "en"},
+		{"de-DE", "de-DE,de;q=0.9,en;q=0.8"},
+		{"ru", "ru,en;q=0.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			if got := acceptLanguage(tt.locale); got != tt.want {
+				t.Errorf("acceptLanguage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_posixToBCP47(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"en_NZ.UTF-8", "en-NZ"},
+		{"de_DE@euro", "de-DE"},
+		{"C", ""},
+		{"POSIX", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := posixToBCP47(tt.in); got != tt.want {
+				t.Errorf("posixToBCP47() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_offsetTimezone(t *testing.T) {
+	tests := []struct {
+		offset int
+		want   string
+	}{
+		{0, "UTC"},
+		{12 * 3600, "Etc/GMT-12"},
+		{-5 * 3600, "Etc/GMT+5"},
+		{5*3600 + 1800, "UTC"}, // India
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := offsetTimezone(tt.offset); got != tt.want {
+				t.Errorf("offsetTimezone() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_viewports(t *testing.T) {
+	for _, goos := range []string{"linux", "windows", "freebsd"} {
+		for _, vp := range viewports(goos) {
+			if vp.scale >= 2 {
+				t.Errorf("%s: unexpected Retina viewport %v", goos, vp)
+			}
+		}
+	}
+	for _, vp := range viewports("darwin") {
+		if vp.scale != 2 && vp.w < 1920 {
+			t.Errorf("darwin: laptop viewport without Retina %v", vp)
+		}
+	}
+}
+
+func Test_options_emulation(t *testing.T) {
+	fp := &Fingerprint{UserAgent: "fp", Locale: "en-NZ", AcceptLanguage: "en-NZ,en;q=0.9", Timezone: "Pacific/Auckland", Width: 1280, Height: 800}
+	tests := []struct {
+		name string
+		opts options
+		want Fingerprint
+	}{
+		{
+			name: "nothing set",
+			opts: options{},
+			want: Fingerprint{},
+		},
+		{
+			name: "locale derives accept-language",
+			opts: options{locale: "de-DE", timezone: "Europe/Berlin"},
+			want: Fingerprint{Locale: "de-DE", AcceptLanguage: "de-DE,de;q=0.9,en;q=0.8", Timezone: "Europe/Berlin"},
+		},
+		{
+			name: "explicit accept-language",
+			opts: options{locale: "de-DE", acceptLanguage: "de"},
+			want: Fingerprint{Locale: "de-DE", AcceptLanguage: "de"},
+		},
+		{
+			name: "options override fingerprint",
+			opts: options{fingerprint: fp, timezone: "UTC"},
+			want: Fingerprint{UserAgent: "fp", Locale: "en-NZ", AcceptLanguage: "en-NZ,en;q=0.9", Timezone: "UTC", Width: 1280, Height: 800},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.opts.emulation())
+		})
+	}
+}
+
+func Test_emulationFlags(t *testing.T) {
+	l := emulationFlags(launcher.New(), Fingerprint{Locale: "de-DE", AcceptLanguage: "de-DE,de;q=0.9", Timezone: "Europe/Berlin"})
+	assert.Equal(t, "de-DE", l.Get("lang"))
+	assert.Equal(t, "de-DE,de;q=0.9", l.Get("accept-lang"))
+	env, ok := l.GetFlags(flags.Env)
+	require.True(t, ok)
+	assert.Contains(t, env, "TZ=Europe/Berlin")
+
+	l = emulationFlags(launcher.New(), Fingerprint{})
+	assert.False(t, l.Has("lang"))
+	assert.False(t, l.Has(flags.Env))
+}
+
+func Test_options_userAgentOverride(t *testing.T) {
+	t.Run("accept-language is added to the user agent", func(t *testing.T) {
+		o := options{userAgent: "ua", locale: "fr-FR"}
+		assert.Equal(t, &proto.NetworkSetUserAgentOverride{UserAgent: "ua", AcceptLanguage: "fr-FR,fr;q=0.9,en;q=0.8"}, o.userAgentOverride())
+	})
+	t.Run("no user agent", func(t *testing.T) {
+		o := options{locale: "fr-FR"}
+		assert.Nil(t, o.userAgentOverride())
+	})
+}
+
+func TestClient_updateFingerprint(t *testing.T) {
+	stale := Fingerprint{
+		UserAgent:         UserAgent("", "110.0.0.0", ""),
+		UserAgentMetadata: &proto.EmulationUserAgentMetadata{FullVersion: "110.0.5481.77"},
+		Width:             1366,
+	}
+	tests := []struct {
+		name   string
+		fp     Fingerprint
+		file   bool
+		wantUA string
+	}{
+		{"new fingerprint file", NewFingerprint(), true, "Chrome/120.0.0.0"},
+		{"stale fingerprint file", stale, true, "Chrome/120.0.0.0"},
+		{"no client hints", Fingerprint{UserAgent: "old"}, true, "Chrome/120.0.0.0"},
+		{"explicit user agent", Fingerprint{UserAgent: "custom"}, false, "custom"},
+		{"explicit, no user agent", Fingerprint{Width: 1366}, false, "Chrome/120.0.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fp := tt.fp
+			c := &Client{opts: options{fingerprint: &fp, lg: discardLogger()}}
+			if tt.file {
+				c.opts.fingerprintFile = filepath.Join(t.TempDir(), "fingerprint.json")
+			}
+			require.NoError(t, c.detectUserAgent(newFakeBrowser()))
+			assert.Contains(t, fp.UserAgent, tt.wantUA)
+			assert.NotContains(t, fp.UserAgent, "Headless")
+			if tt.wantUA == "custom" {
+				return
+			}
+			require.NotNil(t, fp.UserAgentMetadata)
+			assert.Equal(t, "120.0.6099.0", fp.UserAgentMetadata.FullVersion)
+			assert.Equal(t, fp.UserAgentMetadata, c.opts.userAgentOverride().UserAgentMetadata)
+			assert.Equal(t, tt.fp.Width, fp.Width, "only the user agent is updated")
+			if tt.file {
+				saved, err := LoadFingerprint(c.opts.fingerprintFile)
+				require.NoError(t, err)
+				assert.Equal(t, fp, saved)
+			}
+		})
+	}
+}
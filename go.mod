@@ -8,7 +8,10 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/rusq/chttp v1.0.2
 	github.com/stretchr/testify v1.9.0
+	github.com/ysmood/gson v0.7.3
 	go.uber.org/mock v0.4.0
+	golang.org/x/net v0.30.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -17,10 +20,7 @@ require (
 	github.com/ysmood/fetchup v0.2.4 // indirect
 	github.com/ysmood/goob v0.4.0 // indirect
 	github.com/ysmood/got v0.40.0 // indirect
-	github.com/ysmood/gson v0.7.3 // indirect
 	github.com/ysmood/leakless v0.9.0 // indirect
-	golang.org/x/net v0.30.0 // indirect
 	golang.org/x/text v0.19.0 // indirect
 	golang.org/x/xerrors v0.0.0-20220411194840-2f41105eb62f // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,589 @@
+package slackauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// harTimeFormat is the ISO 8601 time format with milliseconds.
+const harTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// HAR 1.2 format, see http://www.softwareishard.com/blog/har-12-spec/.  Only
+// the fields that can be filled from the DevTools network events are
+// present.
+
+type har struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	started time.Time
+
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	ResourceType    string      `json:"_resourceType,omitempty"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []harCookie  `json:"cookies"`
+	Headers     []harNV      `json:"headers"`
+	QueryString []harNV      `json:"queryString"`
+	PostData    *harPostData `json:"postData,omitempty"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []harCookie `json:"cookies"`
+	Headers     []harNV     `json:"headers"`
+	Content     harContent  `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type harCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+type harNV struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string  `json:"mimeType"`
+	Params   []harNV `json:"params,omitempty"`
+	Text     string  `json:"text,omitempty"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harRecorder records the network traffic of the pages from the DevTools
+// network events.
+type harRecorder struct {
+	redact bool
+
+	mu      sync.Mutex
+	done    []*harEntry                         // finished entries
+	active  map[proto.NetworkRequestID]*harCall // requests in flight
+	reqHdrs map[proto.NetworkRequestID]proto.NetworkHeaders
+	rspHdrs map[proto.NetworkRequestID]proto.NetworkHeaders
+}
+
+// harCall is the request in flight.
+type harCall struct {
+	entry   *harEntry
+	started proto.MonotonicTime
+	sent    proto.MonotonicTime // time the response headers were received
+}
+
+func newHARRecorder(redact bool) *harRecorder {
+	return &harRecorder{
+		redact:  redact,
+		active:  make(map[proto.NetworkRequestID]*harCall),
+		reqHdrs: make(map[proto.NetworkRequestID]proto.NetworkHeaders),
+		rspHdrs: make(map[proto.NetworkRequestID]proto.NetworkHeaders),
+	}
+}
+
+// record starts recording the network traffic of the page.
+func (r *harRecorder) record(page *rod.Page) error {
+	wait := page.EachEvent(
+		r.requestWillBeSent,
+		r.requestExtraInfo,
+		r.responseReceived,
+		r.responseExtraInfo,
+		r.loadingFinished,
+		r.loadingFailed,
+	)
+	if err := (proto.NetworkEnable{}).Call(page); err != nil {
+		return err
+	}
+	go wait()
+	return nil
+}
+
+func (r *harRecorder) requestWillBeSent(e *proto.NetworkRequestWillBeSent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if call, ok := r.active[e.RequestID]; ok && e.RedirectResponse != nil {
+		// redirects share the request ID.
+		r.setResponse(call, e.RedirectResponse)
+		if hdrs, ok := r.rspHdrs[e.RequestID]; ok {
+			r.setResponseHeaders(call.entry, hdrs)
+		}
+		r.finish(e.RequestID, e.Timestamp)
+	}
+	req := e.Request
+	started := e.WallTime.Time().UTC()
+	entry := &harEntry{
+		started:         started,
+		StartedDateTime: started.Format(harTimeFormat),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL + req.URLFragment,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harCookie{},
+			QueryString: []harNV{},
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response:     harResponse{Cookies: []harCookie{}, Headers: []harNV{}, HeadersSize: -1, BodySize: -1},
+		ResourceType: string(e.Type),
+	}
+	r.setRequestHeaders(entry, req.Headers)
+	if hdrs, ok := r.reqHdrs[e.RequestID]; ok {
+		// extra info arrived first.
+		r.setRequestHeaders(entry, hdrs)
+		delete(r.reqHdrs, e.RequestID)
+	}
+	if u, err := url.Parse(req.URL); err == nil {
+		for name, values := range u.Query() {
+			for _, v := range values {
+				entry.Request.QueryString = append(entry.Request.QueryString, harNV{Name: name, Value: v})
+			}
+		}
+		sortNV(entry.Request.QueryString)
+	}
+	if body := postData(req); body != "" {
+		entry.Request.BodySize = len(body)
+		entry.Request.PostData = parsePostData(headerValue(req.Headers, "Content-Type"), body)
+	}
+	r.active[e.RequestID] = &harCall{entry: entry, started: e.Timestamp}
+}
+
+func (r *harRecorder) requestExtraInfo(e *proto.NetworkRequestWillBeSentExtraInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if call, ok := r.active[e.RequestID]; ok {
+		r.setRequestHeaders(call.entry, e.Headers)
+		return
+	}
+	r.reqHdrs[e.RequestID] = e.Headers
+}
+
+func (r *harRecorder) responseReceived(e *proto.NetworkResponseReceived) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	call, ok := r.active[e.RequestID]
+	if !ok {
+		return
+	}
+	call.sent = e.Timestamp
+	r.setResponse(call, e.Response)
+	if hdrs, ok := r.rspHdrs[e.RequestID]; ok {
+		r.setResponseHeaders(call.entry, hdrs)
+		delete(r.rspHdrs, e.RequestID)
+	}
+}
+
+func (r *harRecorder) responseExtraInfo(e *proto.NetworkResponseReceivedExtraInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if call, ok := r.active[e.RequestID]; ok && call.entry.Response.Status != 0 {
+		r.setResponseHeaders(call.entry, e.Headers)
+		return
+	}
+	r.rspHdrs[e.RequestID] = e.Headers
+}
+
+func (r *harRecorder) loadingFinished(e *proto.NetworkLoadingFinished) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if call, ok := r.active[e.RequestID]; ok {
+		call.entry.Response.BodySize = int(e.EncodedDataLength)
+		r.finish(e.RequestID, e.Timestamp)
+	}
+}
+
+func (r *harRecorder) loadingFailed(e *proto.NetworkLoadingFailed) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if call, ok := r.active[e.RequestID]; ok {
+		call.entry.Error = e.ErrorText
+		r.finish(e.RequestID, e.Timestamp)
+	}
+}
+
+// finish moves the request to the finished entries, must be called with the
+// mutex held.
+func (r *harRecorder) finish(id proto.NetworkRequestID, ts proto.MonotonicTime) {
+	call := r.active[id]
+	delete(r.active, id)
+	delete(r.rspHdrs, id)
+	e := call.entry
+	e.Time = ms(ts - call.started)
+	if call.sent != 0 {
+		e.Timings = harTimings{Wait: ms(call.sent - call.started), Receive: ms(ts - call.sent)}
+	} else {
+		e.Timings = harTimings{Wait: e.Time}
+	}
+	r.done = append(r.done, e)
+}
+
+func ms(d proto.MonotonicTime) float64 {
+	if d < 0 {
+		return 0
+	}
+	return float64(d) * 1000
+}
+
+func (r *harRecorder) setResponse(call *harCall, resp *proto.NetworkResponse) {
+	e := call.entry
+	e.Response.Status = resp.Status
+	e.Response.StatusText = resp.StatusText
+	e.Response.HTTPVersion = httpVersion(resp.Protocol)
+	e.Request.HTTPVersion = e.Response.HTTPVersion
+	e.Response.Content = harContent{Size: int(resp.EncodedDataLength), MimeType: resp.MIMEType}
+	e.ServerIPAddress = resp.RemoteIPAddress
+	r.setResponseHeaders(e, resp.Headers)
+}
+
+func (r *harRecorder) setRequestHeaders(e *harEntry, hdrs proto.NetworkHeaders) {
+	e.Request.Headers = r.headers(hdrs)
+	e.Request.Cookies = []harCookie{}
+	for _, c := range (&http.Request{Header: httpHeader(hdrs)}).Cookies() {
+		e.Request.Cookies = append(e.Request.Cookies, r.cookie(c))
+	}
+}
+
+func (r *harRecorder) setResponseHeaders(e *harEntry, hdrs proto.NetworkHeaders) {
+	e.Response.Headers = r.headers(hdrs)
+	h := httpHeader(hdrs)
+	e.Response.RedirectURL = h.Get("Location")
+	e.Response.Cookies = []harCookie{}
+	for _, c := range (&http.Response{Header: h}).Cookies() {
+		e.Response.Cookies = append(e.Response.Cookies, r.cookie(c))
+	}
+}
+
+func (r *harRecorder) headers(hdrs proto.NetworkHeaders) []harNV {
+	out := []harNV{}
+	for name, values := range httpHeader(hdrs) {
+		for _, v := range values {
+			out = append(out, harNV{Name: name, Value: v})
+		}
+	}
+	sortNV(out)
+	return out
+}
+
+func (r *harRecorder) cookie(c *http.Cookie) harCookie {
+	hc := harCookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		HTTPOnly: c.HttpOnly,
+		Secure:   c.Secure,
+	}
+	if !c.Expires.IsZero() {
+		hc.Expires = c.Expires.UTC().Format(time.RFC3339)
+	}
+	return hc
+}
+
+// entries returns the recorded entries, including the requests in flight,
+// in the order they were started.
+func (r *harRecorder) entries() []harEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]harEntry, 0, len(r.done)+len(r.active))
+	for _, e := range r.done {
+		out = append(out, *e)
+	}
+	for _, call := range r.active {
+		out = append(out, *call.entry)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].started.Before(out[j].started)
+	})
+	if r.redact {
+		for i := range out {
+			redactEntry(&out[i])
+		}
+	}
+	return out
+}
+
+// WriteTo writes the HAR file.
+func (r *harRecorder) WriteTo(w io.Writer) (int64, error) {
+	doc := har{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "slackauth", Version: moduleVersion()},
+		Entries: r.entries(),
+	}}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// save saves the HAR file to path.
+func (r *harRecorder) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := r.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// recordHAR starts recording the page traffic, if the HAR file is set.  The
+// file is written when the client is closed.
+func (c *Client) recordHAR(page *rod.Page) error {
+	if c.opts.harPath == "" {
+		return nil
+	}
+	if c.har == nil {
+		c.har = newHARRecorder(!c.opts.harUnredacted)
+		c.atClose(func() error {
+			if err := c.har.save(c.opts.harPath); err != nil {
+				return fmt.Errorf("failed to save HAR file: %w", err)
+			}
+			return nil
+		})
+	}
+	return c.har.record(page)
+}
+
+// redactEntry replaces the tokens, passwords and cookie values in the entry.
+func redactEntry(e *harEntry) {
+	e.Request.URL = redactURL(e.Request.URL)
+	e.Request.Headers = redactHeaders(e.Request.Headers)
+	e.Request.Cookies = redactCookies(e.Request.Cookies)
+	e.Request.QueryString = redactParams(e.Request.QueryString)
+	if pd := e.Request.PostData; pd != nil {
+		e.Request.PostData = &harPostData{
+			MimeType: pd.MimeType,
+			Params:   redactParams(pd.Params),
+		}
+		if pd.Text != "" {
+			// the body that is not a form may contain anything.
+			e.Request.PostData.Text = redacted
+		}
+	}
+	e.Response.Headers = redactHeaders(e.Response.Headers)
+	e.Response.Cookies = redactCookies(e.Response.Cookies)
+	e.Response.RedirectURL = redactURL(e.Response.RedirectURL)
+}
+
+func redactHeaders(hdrs []harNV) []harNV {
+	out := make([]harNV, len(hdrs))
+	for i, h := range hdrs {
+		v := h.Value
+		switch strings.ToLower(h.Name) {
+		case "cookie":
+			v = redactCookieHeader(v)
+		case "set-cookie":
+			v = redactSetCookie(v)
+		case "authorization", "proxy-authorization":
+			v = redacted
+		case "location", "referer":
+			v = redactURL(v)
+		default:
+			v = redactString(v)
+		}
+		out[i] = harNV{Name: h.Name, Value: v}
+	}
+	return out
+}
+
+// redactCookieHeader replaces all cookie values in the Cookie header.
+func redactCookieHeader(v string) string {
+	parts := strings.Split(v, ";")
+	for i, p := range parts {
+		if name, _, ok := strings.Cut(p, "="); ok {
+			parts[i] = name + "=" + redacted
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// redactSetCookie replaces the cookie value in the Set-Cookie header.
+func redactSetCookie(v string) string {
+	pair, attrs, _ := strings.Cut(v, ";")
+	name, _, ok := strings.Cut(pair, "=")
+	if !ok {
+		return v
+	}
+	out := name + "=" + redacted
+	if attrs != "" {
+		out += ";" + attrs
+	}
+	return out
+}
+
+func redactCookies(cookies []harCookie) []harCookie {
+	out := make([]harCookie, len(cookies))
+	for i, c := range cookies {
+		c.Value = redacted
+		out[i] = c
+	}
+	return out
+}
+
+func redactParams(params []harNV) []harNV {
+	if params == nil {
+		return nil
+	}
+	out := make([]harNV, len(params))
+	for i, p := range params {
+		if isSensitive(p.Name) {
+			p.Value = redacted
+		} else {
+			p.Value = redactString(p.Value)
+		}
+		out[i] = p
+	}
+	return out
+}
+
+// postData returns the request body.
+func postData(req *proto.NetworkRequest) string {
+	if len(req.PostDataEntries) == 0 {
+		return req.PostData
+	}
+	var b strings.Builder
+	for _, e := range req.PostDataEntries {
+		b.Write(e.Bytes)
+	}
+	return b.String()
+}
+
+// parsePostData parses the URL-encoded and multipart forms into the
+// parameters, other bodies are kept as text.
+func parsePostData(contentType, body string) *harPostData {
+	pd := &harPostData{MimeType: contentType}
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		pd.Text = body
+		return pd
+	}
+	switch mt {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(body)
+		if err != nil {
+			break
+		}
+		for name, vv := range values {
+			for _, v := range vv {
+				pd.Params = append(pd.Params, harNV{Name: name, Value: v})
+			}
+		}
+	case "multipart/form-data":
+		mr := multipart.NewReader(strings.NewReader(body), params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			var buf bytes.Buffer
+			if _, err := io.Copy(&buf, part); err != nil {
+				break
+			}
+			pd.Params = append(pd.Params, harNV{Name: part.FormName(), Value: buf.String()})
+		}
+	}
+	if len(pd.Params) == 0 {
+		pd.Text = body
+		return pd
+	}
+	sortNV(pd.Params)
+	return pd
+}
+
+// httpHeader converts the DevTools headers.  Multiple values of the header
+// are separated by the newline.
+func httpHeader(hdrs proto.NetworkHeaders) http.Header {
+	h := make(http.Header, len(hdrs))
+	for name, v := range hdrs {
+		for _, s := range strings.Split(v.Str(), "\n") {
+			h.Add(name, s)
+		}
+	}
+	return h
+}
+
+func headerValue(hdrs proto.NetworkHeaders, name string) string {
+	return httpHeader(hdrs).Get(name)
+}
+
+func httpVersion(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "", "http/1.1":
+		return "HTTP/1.1"
+	case "h2":
+		return "HTTP/2"
+	case "h3":
+		return "HTTP/3"
+	default:
+		return protocol
+	}
+}
+
+func sortNV(nv []harNV) {
+	sort.SliceStable(nv, func(i, j int) bool { return nv[i].Name < nv[j].Name })
+}
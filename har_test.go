@@ -0,0 +1,310 @@
+package slackauth
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ysmood/gson"
+
+	"github.com/rusq/slackauth/slackauthtest"
+)
+
+const (
+	harTestToken    = "xoxc-1234-5678-abcdef"
+	harTestCookie   = "xoxd-sEcReT%2Fsession"
+	harTestPassword = "hunter2"
+)
+
+func netHeaders(kv ...string) proto.NetworkHeaders {
+	h := make(proto.NetworkHeaders)
+	for i := 0; i < len(kv); i += 2 {
+		h[kv[i]] = gson.New(kv[i+1])
+	}
+	return h
+}
+
+// harSession feeds the recorder with the events of the sign in: the form
+// post, that redirects to the client page, and the api.features request.
+func harSession(r *harRecorder) {
+	wall := proto.TimeSinceEpoch(time.Date(2024, 10, 18, 12, 0, 0, 0, time.UTC).Unix())
+	r.requestWillBeSent(&proto.NetworkRequestWillBeSent{
+		RequestID: "1",
+		Request: &proto.NetworkRequest{
+			Method:   "POST",
+			URL:      "https://acme.slack.com/sign_in_with_password",
+			Headers:  netHeaders("Content-Type", "application/x-www-form-urlencoded"),
+			PostData: "email=me%40example.com&password=" + harTestPassword,
+		},
+		Timestamp: 100,
+		WallTime:  wall,
+		Type:      proto.NetworkResourceTypeDocument,
+	})
+	r.responseExtraInfo(&proto.NetworkResponseReceivedExtraInfo{
+		RequestID: "1",
+		Headers:   netHeaders("Location", "/client/T0123", "Set-Cookie", "d="+harTestCookie+"; Path=/; HttpOnly"),
+	})
+	r.requestWillBeSent(&proto.NetworkRequestWillBeSent{
+		RequestID: "1",
+		Request: &proto.NetworkRequest{
+			Method:  "GET",
+			URL:     "https://acme.slack.com/client/T0123",
+			Headers: netHeaders("Cookie", "d="+harTestCookie+"; b=abc"),
+		},
+		RedirectResponse: &proto.NetworkResponse{Status: 302, StatusText: "Found", Protocol: "h2"},
+		Timestamp:        100.25,
+		WallTime:         wall + 0.25,
+		Type:             proto.NetworkResourceTypeDocument,
+	})
+	r.responseReceived(&proto.NetworkResponseReceived{
+		RequestID: "1",
+		Timestamp: 100.5,
+		Response:  &proto.NetworkResponse{Status: 200, StatusText: "OK", MIMEType: "text/html", Protocol: "h2", Headers: netHeaders("Content-Type", "text/html")},
+	})
+	r.loadingFinished(&proto.NetworkLoadingFinished{RequestID: "1", Timestamp: 100.75, EncodedDataLength: 1024})
+
+	body := "--XYZ\r\nContent-Disposition: form-data; name=\"token\"\r\n\r\n" + harTestToken + "\r\n--XYZ--\r\n"
+	r.requestWillBeSent(&proto.NetworkRequestWillBeSent{
+		RequestID: "2",
+		Request: &proto.NetworkRequest{
+			Method:          "POST",
+			URL:             "https://acme.slack.com/api/api.features?_x_id=1&token=" + harTestToken,
+			Headers:         netHeaders("Content-Type", "multipart/form-data; boundary=XYZ", "Authorization", "Bearer "+harTestToken),
+			PostDataEntries: []*proto.NetworkPostDataEntry{{Bytes: []byte(body)}},
+		},
+		Timestamp: 101,
+		WallTime:  wall + 1,
+		Type:      proto.NetworkResourceTypeFetch,
+	})
+	r.requestExtraInfo(&proto.NetworkRequestWillBeSentExtraInfo{
+		RequestID: "2",
+		Headers:   netHeaders("Content-Type", "multipart/form-data; boundary=XYZ", "Authorization", "Bearer "+harTestToken, "Cookie", "d="+harTestCookie),
+	})
+	r.loadingFailed(&proto.NetworkLoadingFailed{RequestID: "2", Timestamp: 101.5, ErrorText: "net::ERR_FAILED"})
+}
+
+// harTestLink is the magic login link, with the secret part.
+const harTestLink = "https://acme.slack.com/z-app-610187951300-9981196591425-e95b38836efcfc97"
+
+// harLinkSession feeds the recorder with the events of the magic login link
+// redemption: the link, that sets the session cookie and redirects to the
+// client page.
+func harLinkSession(r *harRecorder) {
+	wall := proto.TimeSinceEpoch(time.Date(2024, 10, 18, 12, 0, 0, 0, time.UTC).Unix())
+	r.requestWillBeSent(&proto.NetworkRequestWillBeSent{
+		RequestID: "1",
+		Request:   &proto.NetworkRequest{Method: "GET", URL: harTestLink + "?src=qr_code"},
+		Timestamp: 100,
+		WallTime:  wall,
+		Type:      proto.NetworkResourceTypeDocument,
+	})
+	r.responseExtraInfo(&proto.NetworkResponseReceivedExtraInfo{
+		RequestID: "1",
+		Headers:   netHeaders("Location", "/client/T0123", "Set-Cookie", "d="+harTestCookie+"; Path=/; HttpOnly"),
+	})
+	r.requestWillBeSent(&proto.NetworkRequestWillBeSent{
+		RequestID: "1",
+		Request: &proto.NetworkRequest{
+			Method:  "GET",
+			URL:     "https://acme.slack.com/client/T0123",
+			Headers: netHeaders("Cookie", "d="+harTestCookie, "Referer", harTestLink),
+		},
+		RedirectResponse: &proto.NetworkResponse{Status: 302, StatusText: "Found", Protocol: "h2"},
+		Timestamp:        100.25,
+		WallTime:         wall + 0.25,
+		Type:             proto.NetworkResourceTypeDocument,
+	})
+	r.responseReceived(&proto.NetworkResponseReceived{
+		RequestID: "1",
+		Timestamp: 100.5,
+		Response:  &proto.NetworkResponse{Status: 200, StatusText: "OK", MIMEType: "text/html", Protocol: "h2"},
+	})
+	r.loadingFinished(&proto.NetworkLoadingFinished{RequestID: "1", Timestamp: 100.75})
+}
+
+func decodeHAR(t *testing.T, r *harRecorder) (har, string) {
+	t.Helper()
+	var sb strings.Builder
+	_, err := r.WriteTo(&sb)
+	require.NoError(t, err)
+	var doc har
+	require.NoError(t, json.Unmarshal([]byte(sb.String()), &doc))
+	return doc, sb.String()
+}
+
+func TestHARRecorder(t *testing.T) {
+	r := newHARRecorder(false)
+	harSession(r)
+	doc, _ := decodeHAR(t, r)
+
+	assert.Equal(t, "1.2", doc.Log.Version)
+	assert.Equal(t, "slackauth", doc.Log.Creator.Name)
+	require.Len(t, doc.Log.Entries, 3)
+
+	post := doc.Log.Entries[0]
+	assert.Equal(t, "2024-10-18T12:00:00.000Z", post.StartedDateTime)
+	assert.Equal(t, "POST", post.Request.Method)
+	assert.Equal(t, 302, post.Response.Status)
+	assert.Equal(t, "HTTP/2", post.Response.HTTPVersion)
+	assert.Equal(t, "/client/T0123", post.Response.RedirectURL)
+	assert.Equal(t, []harCookie{{Name: "d", Value: harTestCookie, Path: "/", HTTPOnly: true}}, post.Response.Cookies)
+	assert.Equal(t, []harNV{{"email", "me@example.com"}, {"password", harTestPassword}}, post.Request.PostData.Params)
+	assert.InDelta(t, 250, post.Time, 0.001)
+
+	client := doc.Log.Entries[1]
+	assert.Equal(t, 200, client.Response.Status)
+	assert.Equal(t, 1024, client.Response.BodySize)
+	assert.Len(t, client.Request.Cookies, 2)
+	assert.InDelta(t, 500, client.Time, 0.001)
+	assert.InDelta(t, 250, client.Timings.Wait, 0.001)
+	assert.InDelta(t, 250, client.Timings.Receive, 0.001)
+
+	features := doc.Log.Entries[2]
+	assert.Equal(t, "net::ERR_FAILED", features.Error)
+	assert.Equal(t, []harNV{{"token", harTestToken}}, features.Request.PostData.Params)
+	assert.Equal(t, []harNV{{"_x_id", "1"}, {"token", harTestToken}}, features.Request.QueryString)
+	assert.Equal(t, []harCookie{{Name: "d", Value: harTestCookie}}, features.Request.Cookies)
+}
+
+func TestHARRecorder_redact(t *testing.T) {
+	r := newHARRecorder(true)
+	harSession(r)
+	doc, raw := decodeHAR(t, r)
+
+	for _, secret := range []string{harTestToken, harTestCookie, harTestPassword, "xoxc-", "xoxd-"} {
+		assert.NotContains(t, raw, secret)
+	}
+	require.Len(t, doc.Log.Entries, 3)
+	assert.Equal(t, []harNV{{"email", "me@example.com"}, {"password", redacted}}, doc.Log.Entries[0].Request.PostData.Params)
+	assert.Equal(t, "d="+redacted+"; Path=/; HttpOnly", headerOf(doc.Log.Entries[0].Response.Headers, "Set-Cookie"))
+	assert.Equal(t, "d="+redacted+"; b="+redacted, headerOf(doc.Log.Entries[1].Request.Headers, "Cookie"))
+	assert.Equal(t, redacted, headerOf(doc.Log.Entries[2].Request.Headers, "Authorization"))
+	assert.Contains(t, doc.Log.Entries[2].Request.URL, "_x_id=1")
+}
+
+func TestHARRecorder_redactLink(t *testing.T) {
+	r := newHARRecorder(true)
+	harLinkSession(r)
+	doc, raw := decodeHAR(t, r)
+
+	for _, secret := range []string{"e95b38836efcfc97", harTestCookie} {
+		assert.NotContains(t, raw, secret)
+	}
+	require.Len(t, doc.Log.Entries, 2)
+	assert.Equal(t, "https://acme.slack.com/"+loginLinkRedacted+"?src=qr_code", doc.Log.Entries[0].Request.URL)
+	assert.Equal(t, "https://acme.slack.com/"+loginLinkRedacted, headerOf(doc.Log.Entries[1].Request.Headers, "Referer"))
+}
+
+func headerOf(hdrs []harNV, name string) string {
+	for _, h := range hdrs {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+func TestHARRecorder_inFlight(t *testing.T) {
+	r := newHARRecorder(true)
+	r.requestWillBeSent(&proto.NetworkRequestWillBeSent{
+		RequestID: "1",
+		Request:   &proto.NetworkRequest{Method: "GET", URL: "https://acme.slack.com/"},
+	})
+	doc, _ := decodeHAR(t, r)
+	require.Len(t, doc.Log.Entries, 1)
+	assert.Equal(t, 0, doc.Log.Entries[0].Response.Status)
+}
+
+func Test_parsePostData(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        *harPostData
+	}{
+		{
+			name:        "urlencoded",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "b=2&a=1",
+			want:        &harPostData{MimeType: "application/x-www-form-urlencoded", Params: []harNV{{"a", "1"}, {"b", "2"}}},
+		},
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"a":1}`,
+			want:        &harPostData{MimeType: "application/json", Text: `{"a":1}`},
+		},
+		{
+			name:        "no content type",
+			contentType: "",
+			body:        "blob",
+			want:        &harPostData{Text: "blob"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parsePostData(tt.contentType, tt.body))
+		})
+	}
+}
+
+func Test_redactSetCookie(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"d=xoxd-1; Path=/; HttpOnly", "d=" + redacted + "; Path=/; HttpOnly"},
+		{"b=abc", "b=" + redacted},
+		{"garbage", "garbage"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, redactSetCookie(tt.in))
+	}
+}
+
+func TestE2E_HAR(t *testing.T) {
+	srv := slackauthtest.NewServer()
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "login.har")
+	c := e2eClient(t, srv, WithHAR(path))
+
+	_, _, err := c.Headless(e2eContext(t), slackauthtest.DefaultEmail, slackauthtest.DefaultPassword)
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, secret := range []string{slackauthtest.DefaultToken, slackauthtest.DefaultCookie, slackauthtest.DefaultPassword} {
+		assert.NotContains(t, string(data), secret)
+	}
+	var doc har
+	require.NoError(t, json.Unmarshal(data, &doc))
+	var urls []string
+	for _, e := range doc.Log.Entries {
+		urls = append(urls, e.Request.URL)
+	}
+	assert.Contains(t, urls, srv.URL+"/sign_in_with_password")
+	assert.Contains(t, urls, srv.URL+"/api/api.features")
+}
+
+func TestE2E_HAR_link(t *testing.T) {
+	srv := slackauthtest.NewServer()
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "link.har")
+	c := e2eClient(t, srv, WithHAR(path), WithBrowserFlags(map[string]string{"headless": ""}))
+
+	_, _, err := c.linkAuth(e2eContext(t), srv.LoginLink())
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	link := strings.TrimPrefix(srv.LoginLink(), srv.URL+"/")
+	assert.NotContains(t, string(data), link)
+	assert.Contains(t, string(data), srv.URL+"/"+loginLinkRedacted)
+}
@@ -3,6 +3,7 @@ package slackauth
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"runtime/trace"
 	"strings"
@@ -16,7 +17,7 @@ import (
 type hijacker struct {
 	r      *rod.HijackRouter
 	credsC chan creds
-	lg     Logger
+	lg     *slog.Logger
 }
 
 type creds struct {
@@ -24,7 +25,7 @@ type creds struct {
 	Err   error
 }
 
-func newHijacker(ctx context.Context, page *rod.Page, lg Logger) (*hijacker, error) {
+func newHijacker(ctx context.Context, page *rod.Page, lg *slog.Logger) (*hijacker, error) {
 	hPg := page.Context(ctx)
 	hj := &hijacker{
 		r:      hPg.HijackRequests(),
@@ -46,6 +47,7 @@ func (h *hijacker) hook(rh *rod.Hijack) {
 
 	token, err := extractToken(r)
 	if err != nil {
+		h.lg.Error("failed to extract the token", "err", err)
 		h.credsC <- creds{Err: fmt.Errorf("error parsing token out of request: %v", err)}
 		return
 	}
@@ -0,0 +1,71 @@
+package slackauth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// checkTimeout is the timeout for the workspace check.
+const checkTimeout = 15 * time.Second
+
+// ErrWorkspaceCheck is returned when the workspace existence can't be
+// checked, i.e. due to the network, DNS or unexpected server response.  It
+// means that the workspace may exist, as opposed to [ErrWorkspaceNotFound].
+type ErrWorkspaceCheck struct {
+	URL        string
+	StatusCode int // HTTP status code, if the server responded
+	Err        error
+}
+
+func (e ErrWorkspaceCheck) Error() string {
+	var reason string
+	var dnsErr *net.DNSError
+	switch {
+	case e.StatusCode != 0:
+		reason = fmt.Sprintf("unexpected status code %d", e.StatusCode)
+	case errors.As(e.Err, &dnsErr):
+		reason = fmt.Sprintf("DNS lookup failed: %v", e.Err)
+	case errors.Is(e.Err, context.DeadlineExceeded):
+		reason = fmt.Sprintf("timed out: %v", e.Err)
+	default:
+		reason = fmt.Sprintf("network error: %v", e.Err)
+	}
+	return fmt.Sprintf("unable to check workspace %s: %s", e.URL, reason)
+}
+
+func (e ErrWorkspaceCheck) Unwrap() error {
+	return e.Err
+}
+
+// transport returns the HTTP transport for the requests that the library
+// makes.  It is the transport of the client set with [WithHTTPClient], or
+// the default one, configured with the proxy and the root CAs.
+func (o options) transport() http.RoundTripper {
+	if o.hc != nil {
+		if o.hc.Transport != nil {
+			return o.hc.Transport
+		}
+		return http.DefaultTransport
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if o.proxy != nil {
+		t.Proxy = http.ProxyURL(o.proxy)
+	}
+	if o.rootCAs != nil {
+		t.TLSClientConfig = &tls.Config{RootCAs: o.rootCAs}
+	}
+	return t
+}
+
+// httpClient returns the HTTP client for the requests that the library makes.
+func (o options) httpClient() *http.Client {
+	if o.hc != nil {
+		return o.hc
+	}
+	return &http.Client{Transport: o.transport()}
+}
@@ -0,0 +1,174 @@
+package slackauth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/go-rod/rod"
+)
+
+// Logger is the interface of the legacy loggers, that only have the Debug
+// method, see [WithLogger].  If the logger also has the Info, Warn or Error
+// methods with the same signature, they receive the messages of the
+// corresponding levels.
+type Logger interface {
+	// Debug logs a debug message.
+	Debug(msg string, keyvals ...interface{})
+}
+
+type (
+	infoLogger interface {
+		Info(msg string, keyvals ...interface{})
+	}
+	warnLogger interface {
+		Warn(msg string, keyvals ...interface{})
+	}
+	errorLogger interface {
+		Error(msg string, keyvals ...interface{})
+	}
+)
+
+// NewLoggerHandler returns the slog handler, that forwards the records to
+// the legacy logger l.  The records of the Info, Warn and Error levels go to
+// the methods of the same name, if l has them, otherwise to Debug, with the
+// "level" key added.  Attributes of the groups are prefixed with the group
+// names, i.e. "group.key".
+func NewLoggerHandler(l Logger) slog.Handler {
+	return &loggerHandler{l: l}
+}
+
+type loggerHandler struct {
+	l      Logger
+	attrs  []interface{} // key-value pairs of the preformatted attributes
+	prefix string        // group prefix, i.e. "group."
+}
+
+func (h *loggerHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *loggerHandler) Handle(_ context.Context, r slog.Record) error {
+	keyvals := make([]interface{}, 0, len(h.attrs)+2*r.NumAttrs()+2)
+	keyvals = append(keyvals, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		keyvals = appendAttr(keyvals, h.prefix, a)
+		return true
+	})
+	h.logFunc(r.Level, &keyvals)(r.Message, keyvals...)
+	return nil
+}
+
+// logFunc returns the method of the logger for the level.  If the logger
+// does not have it, the level is added to the keyvals.
+func (h *loggerHandler) logFunc(level slog.Level, keyvals *[]interface{}) func(string, ...interface{}) {
+	switch {
+	case level >= slog.LevelError:
+		if l, ok := h.l.(errorLogger); ok {
+			return l.Error
+		}
+	case level >= slog.LevelWarn:
+		if l, ok := h.l.(warnLogger); ok {
+			return l.Warn
+		}
+	case level >= slog.LevelInfo:
+		if l, ok := h.l.(infoLogger); ok {
+			return l.Info
+		}
+	default:
+		return h.l.Debug
+	}
+	*keyvals = append(*keyvals, slog.LevelKey, level.String())
+	return h.l.Debug
+}
+
+func (h *loggerHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append([]interface{}(nil), h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = appendAttr(h2.attrs, h.prefix, a)
+	}
+	return &h2
+}
+
+func (h *loggerHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// appendAttr appends the attribute to keyvals, expanding the groups.
+func appendAttr(keyvals []interface{}, prefix string, a slog.Attr) []interface{} {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return keyvals
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			keyvals = appendAttr(keyvals, prefix, ga)
+		}
+		return keyvals
+	}
+	return append(keyvals, prefix+a.Key, a.Value.Any())
+}
+
+// toSlog returns the slog logger for l.  The slog loggers are used as is,
+// the other loggers are adapted with [NewLoggerHandler], and nil discards
+// the messages.
+func toSlog(l Logger) *slog.Logger {
+	switch l := l.(type) {
+	case nil:
+		return discardLogger()
+	case *slog.Logger:
+		if l == nil {
+			return discardLogger()
+		}
+		return l
+	default:
+		return slog.New(NewLoggerHandler(l))
+	}
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// rodLogger routes the rod traces, see [WithDebug], to the logger at the
+// debug level.  The typed text and the pressed keys are redacted, as they
+// may be the credentials.
+type rodLogger struct {
+	lg *slog.Logger
+}
+
+// inputTraces are the prefixes of the rod input traces, that are followed
+// by the typed text or the key.
+var inputTraces = []string{"input ", "insert text ", "press key: ", "release key: "}
+
+func (r rodLogger) Println(vs ...interface{}) {
+	if len(vs) > 1 && vs[0] == rod.TraceTypeInput {
+		if msg, ok := vs[1].(string); ok {
+			vs = slices.Clone(vs)
+			vs[1] = redactInput(msg)
+		}
+	}
+	r.lg.Debug("rod", "trace", strings.TrimSpace(fmt.Sprintln(vs...)))
+}
+
+// redactInput redacts the text of the rod input trace message.
+func redactInput(msg string) string {
+	for _, prefix := range inputTraces {
+		if strings.HasPrefix(msg, prefix) {
+			return prefix + redacted
+		}
+	}
+	return msg
+}
@@ -0,0 +1,103 @@
+package slackauth
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// debugLogger is the legacy logger, that only has the Debug method.
+type debugLogger struct {
+	lines []string
+}
+
+func (l *debugLogger) Debug(msg string, keyvals ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf("DEBUG %s%v", msg, keyvals))
+}
+
+// leveledLogger is the legacy logger with all levels.
+type leveledLogger struct {
+	debugLogger
+}
+
+func (l *leveledLogger) Info(msg string, keyvals ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf("INFO %s%v", msg, keyvals))
+}
+
+func (l *leveledLogger) Warn(msg string, keyvals ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf("WARN %s%v", msg, keyvals))
+}
+
+func (l *leveledLogger) Error(msg string, keyvals ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf("ERROR %s%v", msg, keyvals))
+}
+
+func logAll(lg *slog.Logger) {
+	lg.Debug("d", "k", 1)
+	lg.Info("i")
+	lg.Warn("w")
+	lg.Error("e")
+}
+
+func TestNewLoggerHandler(t *testing.T) {
+	t.Run("debug only", func(t *testing.T) {
+		var l debugLogger
+		logAll(slog.New(NewLoggerHandler(&l)))
+		assert.Equal(t, []string{
+			"DEBUG d[k 1]",
+			"DEBUG i[level INFO]",
+			"DEBUG w[level WARN]",
+			"DEBUG e[level ERROR]",
+		}, l.lines)
+	})
+	t.Run("all levels", func(t *testing.T) {
+		var l leveledLogger
+		logAll(slog.New(NewLoggerHandler(&l)))
+		assert.Equal(t, []string{"DEBUG d[k 1]", "INFO i[]", "WARN w[]", "ERROR e[]"}, l.lines)
+	})
+	t.Run("attributes and groups", func(t *testing.T) {
+		var l leveledLogger
+		lg := slog.New(NewLoggerHandler(&l)).With("client", "acme").WithGroup("req").With("id", 7)
+		lg.Info("sent", "url", "/x", slog.Group("rsp", "code", 200), slog.Group("", "inline", true))
+		assert.Equal(t, []string{"INFO sent[client acme req.id 7 req.url /x req.rsp.code 200 req.inline true]"}, l.lines)
+	})
+}
+
+func Test_toSlog(t *testing.T) {
+	sl := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	assert.Same(t, sl, toSlog(sl), "slog logger must be used as is")
+
+	var nilSlog *slog.Logger
+	for _, l := range []Logger{nil, nilSlog} {
+		lg := toSlog(l)
+		if assert.NotNil(t, lg) {
+			lg.Error("discarded")
+		}
+	}
+
+	var dl debugLogger
+	toSlog(&dl).Warn("adapted")
+	assert.Equal(t, []string{"DEBUG adapted[level WARN]"}, dl.lines)
+}
+
+func Test_rodLogger(t *testing.T) {
+	var buf bytes.Buffer
+	rodLogger{slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))}.Println("[rod]", "Page.navigate")
+	assert.True(t, strings.Contains(buf.String(), `level=DEBUG msg=rod trace="[rod] Page.navigate"`), buf.String())
+
+	buf.Reset()
+	rodLogger{slog.New(slog.NewTextHandler(&buf, nil))}.Println("[rod]", "Page.navigate")
+	assert.Empty(t, buf.String(), "traces are logged at the debug level")
+}
+
+func TestWithLogger(t *testing.T) {
+	var l leveledLogger
+	var o options
+	WithLogger(&l)(&o)
+	o.lg.Warn("no browsers found")
+	assert.Equal(t, []string{"WARN no browsers found[]"}, l.lines)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/devices"
 	"github.com/go-rod/rod/lib/input"
+	"github.com/go-rod/rod/lib/launcher"
 	"github.com/go-rod/rod/lib/proto"
 )
 
@@ -54,19 +55,26 @@ func Headless(ctx context.Context, workspace, email, password string, opt ...Opt
 // additional user interaction, except the challenge code.  Optional callback
 // function can be provided, it will be called if the challenge code is
 // required.
-func (c *Client) Headless(ctx context.Context, email, password string, callback ...func()) (string, []*http.Cookie, error) {
+func (c *Client) Headless(ctx context.Context, email, password string, callback ...func()) (_ string, _ []*http.Cookie, err error) {
 	ctx, task := trace.NewTask(ctx, "Headless")
 	defer task.End()
 
+	c.prog.begin()
+	c.secrets.add(password)
+	defer func() { err = c.failed(err) }()
+
 	browser, err := c.startPuppet(ctx, !c.opts.debug)
 	if err != nil {
 		return "", nil, err
 	}
+	c.prog.event(EventBrowserLaunched)
+	defer func() { err = c.saveArtifacts(ctx, browser, err) }()
 
 	page, h, err := c.openSlackAuthTab(ctx, browser)
 	if err != nil {
 		return "", nil, err
 	}
+	c.prog.emit(Event{Type: EventPageLoaded, URL: c.wspURL + pathPwdSignin})
 	cb := func() {}
 	if len(callback) > 0 {
 		cb = callback[0]
@@ -86,10 +94,14 @@ func (c *Client) Headless(ctx context.Context, email, password string, callback
 	if err != nil {
 		return "", nil, err
 	}
-	cookies, err := convertCookies(browser.GetCookies())
+	c.secrets.add(token)
+	c.prog.event(EventTokenCaptured)
+	cookies, err := c.browserCookies(ctx, browser)
 	if err != nil {
-		return "", nil, ErrBrowser{Err: err, FailedTo: "extract cookies"}
+		return "", nil, err
 	}
+	c.secrets.addCookies(cookies)
+	c.prog.emit(Event{Type: EventCookiesExtracted, Cookies: len(cookies)})
 
 	return token, cookies, nil
 }
@@ -109,15 +121,16 @@ func SimpleChallengeFn(email string) (int, error) {
 
 const codeLen = 6
 
-// enterCode enters the 6-digit code into the challenge code input fields.
-func enterCode(page elementer, code int) error {
+// enterCode enters the 6-digit code into the challenge code input fields,
+// digitN is the format of the digit input field selector.
+func enterCode(page elementer, digitN string, code int) error {
 	if code > 999999 || code < 0 {
 		return fmt.Errorf("code must be a 6-digit number, got %d", code)
 	}
 	sCode := fmt.Sprintf("%0*d", codeLen, code)
 
 	for i := 1; i <= codeLen; i++ {
-		id := fmt.Sprintf(idDigitN, i)
+		id := fmt.Sprintf(digitN, i)
 		el, err := page.Element(id)
 		if err != nil {
 			return ErrBrowser{Err: err, FailedTo: "find digit input field"}
@@ -131,35 +144,42 @@ func enterCode(page elementer, code int) error {
 }
 
 // startPuppet starts a new browser instance and returns a handle to it.  It ignores
-// user browser flag and always starts an incognito browser.
+// user browser flag and always starts an incognito browser, unless the client
+// is configured to attach to the running browser.
 func (c *Client) startPuppet(ctx context.Context, headless bool) (*rod.Browser, error) {
 	ctx, task := trace.NewTask(ctx, "startPuppet")
 	defer task.End()
 
-	l := c.newBrwsrLauncher(headless)
-
-	url, err := l.Context(ctx).Launch()
+	url, err := c.controlURL(ctx, func() (*launcher.Launcher, error) {
+		return c.newBrwsrLauncher(ctx, headless)
+	})
 	if err != nil {
-		return nil, ErrBrowser{Err: err, FailedTo: "launch, you may need to close your browser first"}
+		return nil, err
 	}
-	c.atClose(toerrfn(l.Cleanup))
 
 	var delay time.Duration = 0
 	if c.opts.debug {
 		delay = debugDelay
 	}
 
+	bctx := c.browserContext(ctx)
 	browser := rod.New().
-		Context(ctx).
-		ControlURL(url).
+		Context(bctx).
 		DefaultDevice(devices.Clear).
 		Trace(c.opts.debug).
+		Logger(rodLogger{c.opts.lg}).
 		SlowMotion(delay)
-	c.atClose(browser.Close)
 
-	if err := browser.Connect(); err != nil {
+	if err := c.connect(bctx, browser, url); err != nil {
 		return nil, ErrBrowser{Err: err, FailedTo: "connect"}
 	}
+	c.atCloseBrowser(browser)
+	if err := c.handleProxyAuth(browser); err != nil {
+		return nil, err
+	}
+	if err := c.detectUserAgent(browser); err != nil {
+		return nil, ErrBrowser{Err: err, FailedTo: "detect browser version"}
+	}
 
 	return browser, nil
 }
@@ -170,6 +190,7 @@ func (c *Client) doAutoLogin(ctx context.Context, page *rod.Page, email, passwor
 	ctx, task := trace.NewTask(ctx, "doAutoLogin")
 	defer task.End()
 
+	sel := c.opts.selectors
 	page = page.Context(ctx)
 	// ensure the page is loaded before starting fiddling with it.
 	if err := page.WaitLoad(); err != nil {
@@ -177,42 +198,44 @@ func (c *Client) doAutoLogin(ctx context.Context, page *rod.Page, email, passwor
 	}
 	// if there's no password element on the page, we must be on the "email
 	// login" page.  We need to switch away to the password login.
-	if hasPwdField, _, err := page.Has(idPassword); err != nil {
+	if hasPwdField, _, err := page.Has(sel.Password); err != nil {
 		return ErrBrowser{Err: err, FailedTo: "check for password field"}
 	} else if !hasPwdField {
 		c.opts.lg.Debug("switching to password login")
-		el, err := page.Element(idPasswordLogin)
+		el, err := page.Element(sel.PasswordLogin)
 		if err != nil {
 			return ErrBrowser{Err: err, FailedTo: "find password login link"}
 		}
 		if err := el.Click(proto.InputMouseButtonLeft, 1); err != nil {
 			return ErrBrowser{Err: err, FailedTo: "click password login link"}
 		}
+		c.prog.event(EventPasswordLogin)
 	}
 	// fill in email and password fields.
-	if fldEmail, err := page.Element(idEmail); err != nil {
+	if fldEmail, err := page.Element(sel.Email); err != nil {
 		return ErrBrowser{Err: err, FailedTo: "find email field"}
 	} else {
-		if err := fldEmail.Input(email); err != nil {
+		if err := c.input(fldEmail, email); err != nil {
 			return ErrBrowser{Err: err, FailedTo: "fill in email field"}
 		}
 	}
-	if fldPwd, err := page.Element(idPassword); err != nil {
+	if fldPwd, err := page.Element(sel.Password); err != nil {
 		return ErrBrowser{Err: err, FailedTo: "find password field"}
 	} else {
-		if err := fldPwd.Input(password); err != nil {
+		if err := c.input(fldPwd, password); err != nil {
 			return ErrBrowser{Err: err, FailedTo: "fill in password field"}
 		}
 		if err := fldPwd.Type(input.Enter); err != nil {
 			return ErrBrowser{Err: err, FailedTo: "submit login form"}
 		}
+		c.prog.event(EventCredentialsSubmitted)
 	}
-	rctx := page.Race().Element(idAnyError).Handle(func(e *rod.Element) error {
+	rctx := page.Race().Element(sel.AnyError).Handle(func(e *rod.Element) error {
 		rgn := trace.StartRegion(page.GetContext(), "idAnyError")
 		defer rgn.End()
 		c.opts.lg.Debug("looks like some error occurred")
-		if has, _, err := page.Has(idPasswordError); err == nil && has {
-			el, err := page.Element(idSignInAlertText)
+		if has, _, err := page.Has(sel.PasswordError); err == nil && has {
+			el, err := page.Element(sel.SignInAlertText)
 			if err != nil {
 				return ErrInvalidCredentials
 			}
@@ -223,27 +246,28 @@ func (c *Client) doAutoLogin(ctx context.Context, page *rod.Page, email, passwor
 			return fmt.Errorf("%w, slack message: [%s]", ErrInvalidCredentials, txt)
 		}
 		return ErrLoginError
-	}).Element(idUnknownBrowser).Handle(func(e *rod.Element) error {
+	}).Element(sel.UnknownBrowser).Handle(func(e *rod.Element) error {
 		rgn := trace.StartRegion(page.GetContext(), "idUnknownBrowser")
 		defer rgn.End()
-		c.opts.lg.Debug("looks like we're on the unknown browser page")
+		c.opts.lg.Warn("slack does not recognise the browser, the challenge code is required")
+		c.prog.event(EventChallengeRequested)
 		challengeCb() // call the challenge callback function
 		code, err := c.opts.codeFn(email)
 		if err != nil {
 			return fmt.Errorf("failed to get challenge code: %w", err)
 		}
 		wrapped := (*pageWrapper)(page)
-		if err := enterCode(wrapped, code); err != nil {
+		if err := enterCode(wrapped, sel.DigitN, code); err != nil {
 			return ErrBrowser{Err: err, FailedTo: "enter challenge code"}
 		}
 		_, err = page.Race().
-			Element(idRedirect).Handle(click).
-			Element(idCodeError).Handle(
+			Element(sel.Redirect).Handle(c.clickRedirect).
+			Element(sel.CodeError).Handle(
 			func(e *rod.Element) error {
 				return ErrInvalidChallengeCode
 			}).Do()
 		return err
-	}).Element(idRedirect).Handle(click) // success
+	}).Element(sel.Redirect).Handle(c.clickRedirect) // success
 	if _, err := rctx.Do(); err != nil {
 		return ErrBrowser{Err: err, FailedTo: "wait for login to complete"}
 	}
@@ -79,7 +79,7 @@ func Test_enterCode(t *testing.T) {
 			e := NewMockelementer(ctrl)
 			ip := NewMockinputter(ctrl)
 			tt.expect(e, ip)
-			if err := enterCode(e, tt.args.code); (err != nil) != tt.wantErr {
+			if err := enterCode(e, idDigitN, tt.args.code); (err != nil) != tt.wantErr {
 				t.Errorf("enterCode() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -0,0 +1,244 @@
+package slackauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"runtime/trace"
+	"strings"
+	"sync"
+
+	"github.com/rusq/slackauth/internal/qrslack"
+)
+
+const (
+	cookieD          = "d"     // name of the session cookie
+	maxBootPageSz    = 8 << 20 // maximum size of the workspace boot page
+	maxLinkRedirects = 10      // maximum number of redirects to follow
+	titleExpired     = "Link expired"
+)
+
+var (
+	// ErrNoSessionCookie indicates that the login link did not result in the
+	// session cookie being set.
+	ErrNoSessionCookie = errors.New("session cookie not found")
+	// ErrNoToken indicates that the token was not found on the workspace
+	// page.
+	ErrNoToken = errors.New("token not found")
+)
+
+// reAPIToken matches the token in the workspace boot data.
+var reAPIToken = regexp.MustCompile(`"api_token"\s*:\s*"(xoxc-[a-zA-Z0-9-]+)"`)
+
+// QRAuthHTTP is the browserless counterpart of [Client.QRAuth].  It decodes
+// the QR code image and redeems the login link with the HTTP client, see
+// [Client.RedeemLink].
+func (c *Client) QRAuthHTTP(ctx context.Context, imageData string) (string, []*http.Cookie, error) {
+	ctx, task := trace.NewTask(ctx, "QRAuthHTTP")
+	defer task.End()
+
+	loginURL, err := qrslack.Decode(imageData)
+	if err != nil {
+		return "", nil, err
+	}
+	return c.RedeemLink(ctx, loginURL)
+}
+
+// RedeemLink redeems the magic login link (the one that is sent by email, or
+// encoded in the QR code) without starting the browser.  It follows the link
+// with the HTTP client, captures the session cookie and extracts the token
+// from the workspace page.  The login link is redacted in the returned
+// errors.
+func (c *Client) RedeemLink(ctx context.Context, loginURL string) (_ string, _ []*http.Cookie, err error) {
+	ctx, task := trace.NewTask(ctx, "RedeemLink")
+	defer task.End()
+
+	// the errors of the HTTP client contain the link.
+	defer func() { err = c.secrets.redactErr(err) }()
+
+	if _, err := url.Parse(loginURL); err != nil {
+		return "", nil, fmt.Errorf("invalid login link: %w", err)
+	}
+
+	hc, rec, err := c.linkClient()
+	if err != nil {
+		return "", nil, err
+	}
+
+	c.opts.lg.Debug("redeeming login link")
+	body, err := c.get(ctx, hc, loginURL)
+	if err != nil {
+		return "", nil, err
+	}
+	if isLinkExpired(body) {
+		return "", nil, ErrLinkExpired
+	}
+	if !rec.has(cookieD) {
+		return "", nil, ErrNoSessionCookie
+	}
+
+	// the page we've been redirected to may already contain the boot data,
+	// if not, we request the workspace page.
+	token, err := findToken(body)
+	if err != nil {
+		c.opts.lg.Debug("token not found on the landing page, requesting workspace")
+		body, err = c.get(ctx, hc, c.wspURL)
+		if err != nil {
+			return "", nil, err
+		}
+		if token, err = findToken(body); err != nil {
+			return "", nil, err
+		}
+	}
+
+	cookies := rec.cookies()
+	c.secrets.add(token)
+	c.secrets.addCookies(cookies)
+	return token, cookies, nil
+}
+
+// linkClient returns the HTTP client with the cookie jar, prepopulated with
+// the client cookies, and the recorder, that keeps track of all cookies that
+// were set by the server.
+func (c *Client) linkClient() (*http.Client, *cookieRecorder, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(c.opts.cookies) > 0 {
+		u, err := url.Parse(c.wspURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		jar.SetCookies(u, c.opts.cookies)
+	}
+	rec := &cookieRecorder{rt: c.opts.transport()}
+	hc := &http.Client{
+		Jar:       jar,
+		Transport: rec,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxLinkRedirects {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+	return hc, rec, nil
+}
+
+// get requests the uri and returns the response body.
+func (c *Client) get(ctx context.Context, hc *http.Client, uri string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	ua := c.opts.userAgent
+	if ua == "" {
+		ua = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", ua)
+	resp, err := hc.Do(req)
+	if err != nil {
+		if cerr := certError(uri, err); cerr != nil {
+			return nil, cerr
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxBootPageSz))
+}
+
+// findToken finds the token in the workspace boot data.
+func findToken(body []byte) (string, error) {
+	m := reAPIToken.FindSubmatch(body)
+	if len(m) < 2 {
+		return "", ErrNoToken
+	}
+	return string(m[1]), nil
+}
+
+// reTitle matches the page title.
+var reTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// isLinkExpired returns true if the page title reports that the link has
+// expired.
+func isLinkExpired(body []byte) bool {
+	m := reTitle.FindSubmatch(body)
+	if len(m) < 2 {
+		return false
+	}
+	return strings.Contains(string(m[1]), titleExpired)
+}
+
+// cookieRecorder is a http.RoundTripper that records all cookies set by the
+// server.  Unlike the cookie jar, it preserves all cookie attributes.
+type cookieRecorder struct {
+	rt http.RoundTripper
+
+	mu  sync.Mutex
+	set []*http.Cookie
+}
+
+func (r *cookieRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	cc := resp.Cookies()
+	if len(cc) == 0 {
+		return resp, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range cc {
+		if c.Domain == "" {
+			c.Domain = req.URL.Hostname()
+		}
+		if c.Path == "" {
+			c.Path = "/"
+		}
+		r.put(c)
+	}
+	return resp, nil
+}
+
+// put adds or replaces the cookie, must be called with the mutex held.
+func (r *cookieRecorder) put(c *http.Cookie) {
+	for i, have := range r.set {
+		if have.Name == c.Name && have.Domain == c.Domain && have.Path == c.Path {
+			r.set[i] = c
+			return
+		}
+	}
+	r.set = append(r.set, c)
+}
+
+// has returns true if the cookie with the given name has been set, and has
+// a non-empty value.
+func (r *cookieRecorder) has(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.set {
+		if c.Name == name && c.Value != "" && c.MaxAge >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// cookies returns the recorded cookies.
+func (r *cookieRecorder) cookies() []*http.Cookie {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]*http.Cookie, len(r.set))
+	copy(out, r.set)
+	return out
+}
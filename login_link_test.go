@@ -0,0 +1,94 @@
+package slackauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "xoxc-123-456-789-abcdef"
+
+func fakeLinkServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/z-app-ok", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: cookieD, Value: "xoxd-session", Path: "/", HttpOnly: true})
+		http.Redirect(w, r, "/", http.StatusFound)
+	})
+	mux.HandleFunc("/z-app-expired", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>Link expired | Slack</title></head></html>`))
+	})
+	mux.HandleFunc("/z-app-nocookie", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/", http.StatusFound)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie(cookieD); err != nil || c.Value == "" {
+			w.Write([]byte(`<html><head><title>Sign in | Slack</title></head></html>`))
+			return
+		}
+		w.Write([]byte(`<script>var boot_data = {"api_token":"` + testToken + `","other":1};</script>`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_RedeemLink(t *testing.T) {
+	srv := fakeLinkServer(t)
+	tests := []struct {
+		name      string
+		path      string
+		wantToken string
+		wantErr   error
+	}{
+		{"valid link", "/z-app-ok", testToken, nil},
+		{"expired link", "/z-app-expired", "", ErrLinkExpired},
+		{"no session cookie", "/z-app-nocookie", "", ErrNoSessionCookie},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{wspURL: srv.URL + "/", opts: options{lg: discardLogger()}}
+			token, cookies, err := c.RedeemLink(context.Background(), srv.URL+tt.path)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantToken, token)
+			require.Len(t, cookies, 1)
+			assert.Equal(t, cookieD, cookies[0].Name)
+			assert.Equal(t, "127.0.0.1", cookies[0].Domain)
+			assert.True(t, cookies[0].HttpOnly)
+		})
+	}
+}
+
+func Test_findToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{"found", `{"api_token":"` + testToken + `"}`, testToken, false},
+		{"found with spaces", `{"api_token" : "` + testToken + `"}`, testToken, false},
+		{"not xoxc", `{"api_token":"xoxb-123"}`, "", true},
+		{"empty", ``, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findToken([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("findToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("findToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -26,18 +26,24 @@ func Manual(ctx context.Context, workspace string, opt ...Option) (string, []*ht
 }
 
 // Manual initiates a login flow in a browser (manual login).
-func (c *Client) Manual(ctx context.Context) (string, []*http.Cookie, error) {
+func (c *Client) Manual(ctx context.Context) (_ string, _ []*http.Cookie, err error) {
 	ctx, task := trace.NewTask(ctx, "Manual")
 	defer task.End()
 
+	c.prog.begin()
+	defer func() { err = c.failed(err) }()
+
 	browser, err := c.startBrowser(ctx)
 	if err != nil {
 		return "", nil, err
 	}
+	c.prog.event(EventBrowserLaunched)
+	defer func() { err = c.saveArtifacts(ctx, browser, err) }()
 	page, h, err := c.openSlackAuthTab(ctx, browser)
 	if err != nil {
 		return "", nil, err
 	}
+	c.prog.emit(Event{Type: EventPageLoaded, URL: c.wspURL + pathPwdSignin})
 
 	ctx, cancel := withTabGuard(ctx, browser, page.TargetID, c.opts.lg)
 	defer cancel(nil)
@@ -50,18 +56,15 @@ func (c *Client) Manual(ctx context.Context) (string, []*http.Cookie, error) {
 	if err != nil {
 		return "", nil, err
 	}
+	c.secrets.add(token)
+	c.prog.event(EventTokenCaptured)
 
-	var cookies []*http.Cookie
-	cookies, err = convertCookies(browser.GetCookies())
-	if c.opts.forceUser {
-		// we need not store all cookies from the user browser.
-		trace.WithRegion(ctx, "filterCookies", func() {
-			cookies = filterCookies(cookies)
-		})
-	}
+	cookies, err := c.browserCookies(ctx, browser)
 	if err != nil {
-		return "", nil, ErrBrowser{Err: err, FailedTo: "extract cookies"}
+		return "", nil, err
 	}
+	c.secrets.addCookies(cookies)
+	c.prog.emit(Event{Type: EventCookiesExtracted, Cookies: len(cookies)})
 
 	return token, cookies, nil
 }
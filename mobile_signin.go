@@ -20,11 +20,23 @@ func (c *Client) QRAuth(ctx context.Context, imageData string) (string, []*http.
 	if err != nil {
 		return "", nil, err
 	}
+	return c.linkAuth(ctx, loginURL)
+}
+
+// linkAuth opens the magic login link in the browser and waits for the token.
+func (c *Client) linkAuth(ctx context.Context, loginURL string) (_ string, _ []*http.Cookie, err error) {
+	ctx, task := trace.NewTask(ctx, "linkAuth")
+	defer task.End()
+
+	c.prog.begin()
+	defer func() { err = c.failed(err) }()
 
 	browser, err := c.startBrowser(ctx)
 	if err != nil {
 		return "", nil, err
 	}
+	c.prog.event(EventBrowserLaunched)
+	defer func() { err = c.saveArtifacts(ctx, browser, err) }()
 	page, h, err := c.blankPage(ctx, browser)
 	if err != nil {
 		return "", nil, err
@@ -33,6 +45,11 @@ func (c *Client) QRAuth(ctx context.Context, imageData string) (string, []*http.
 	if err := c.openURL(ctx, page, loginURL); err != nil {
 		return "", nil, err
 	}
+	if err := page.Context(ctx).WaitLoad(); err != nil {
+		return "", nil, ErrBrowser{Err: err, FailedTo: "load page"}
+	}
+	// the login link is the secret, it is not reported.
+	c.prog.event(EventPageLoaded)
 
 	ctx, cancel := withTabGuard(ctx, browser, page.TargetID, c.opts.lg)
 	defer cancel(nil)
@@ -42,7 +59,7 @@ func (c *Client) QRAuth(ctx context.Context, imageData string) (string, []*http.
 	defer stopTrap(errors.New("login finished"))
 
 	title := page.MustElement("title").MustEval(`() => this.innerText`).String()
-	if strings.Contains(title, "Link expired") {
+	if strings.Contains(title, titleExpired) {
 		return "", nil, ErrLinkExpired
 	}
 
@@ -51,18 +68,15 @@ func (c *Client) QRAuth(ctx context.Context, imageData string) (string, []*http.
 	if err != nil {
 		return "", nil, err
 	}
+	c.secrets.add(token)
+	c.prog.event(EventTokenCaptured)
 
-	var cookies []*http.Cookie
-	cookies, err = convertCookies(browser.GetCookies())
-	if c.opts.forceUser {
-		// we need not store all cookies from the user browser.
-		trace.WithRegion(ctx, "filterCookies", func() {
-			cookies = filterCookies(cookies)
-		})
-	}
+	cookies, err := c.browserCookies(ctx, browser)
 	if err != nil {
-		return "", nil, ErrBrowser{Err: err, FailedTo: "extract cookies"}
+		return "", nil, err
 	}
+	c.secrets.addCookies(cookies)
+	c.prog.emit(Event{Type: EventCookiesExtracted, Cookies: len(cookies)})
 
 	return token, cookies, nil
 }
@@ -0,0 +1,357 @@
+package slackauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+const defaultProfile = "Default"
+
+// snapshotFiles is the list of files and directories, relative to the user
+// data directory, that are copied to the profile snapshot.  "%s" is replaced
+// with the profile directory name.
+var snapshotFiles = []string{
+	"Local State", // contains the cookie encryption key on Windows and Linux
+	"%s/Cookies",
+	"%s/Cookies-journal",
+	"%s/Network/Cookies",
+	"%s/Network/Cookies-journal",
+	"%s/Local Storage",
+	"%s/Login Data",
+	"%s/Login Data-journal",
+	"%s/Preferences",
+	"%s/Secure Preferences",
+	"%s/Web Data",
+}
+
+// skipFiles are the lock files that must not be copied, as they belong to
+// the running browser.
+var skipFiles = map[string]bool{
+	"LOCK":            true,
+	"SingletonLock":   true,
+	"SingletonCookie": true,
+	"SingletonSocket": true,
+	"lockfile":        true,
+}
+
+// Profile is the browser profile.
+type Profile struct {
+	// Dir is the profile directory name, i.e. "Default" or "Profile 2".
+	Dir string
+	// Name is the profile display name.
+	Name string
+	// Email is the email of the signed-in user, if any.
+	Email string
+}
+
+// Profiles returns the list of browser profiles, as listed in the "Local
+// State" file of the browser user data directory.
+func (b LocalBrowser) Profiles() ([]Profile, error) {
+	dir, err := userDataDir(b)
+	if err != nil {
+		return nil, err
+	}
+	return readProfiles(dir)
+}
+
+// localState is the subset of the "Local State" file of the browser.
+type localState struct {
+	Profile struct {
+		InfoCache map[string]struct {
+			Name     string `json:"name"`
+			UserName string `json:"user_name"`
+		} `json:"info_cache"`
+	} `json:"profile"`
+}
+
+// readProfiles reads the list of profiles from the "Local State" file in
+// the user data directory.  Default profile is always first, the rest are
+// sorted by the directory name.
+func readProfiles(userDataDir string) ([]Profile, error) {
+	data, err := os.ReadFile(filepath.Join(userDataDir, "Local State"))
+	if err != nil {
+		return nil, err
+	}
+	var ls localState
+	if err := json.Unmarshal(data, &ls); err != nil {
+		return nil, fmt.Errorf("failed to parse Local State: %w", err)
+	}
+	var profiles = make([]Profile, 0, len(ls.Profile.InfoCache))
+	for dir, info := range ls.Profile.InfoCache {
+		profiles = append(profiles, Profile{Dir: dir, Name: info.Name, Email: info.UserName})
+	}
+	slices.SortFunc(profiles, func(a, b Profile) int {
+		switch {
+		case a.Dir == defaultProfile:
+			return -1
+		case b.Dir == defaultProfile:
+			return 1
+		default:
+			return strings.Compare(a.Dir, b.Dir)
+		}
+	})
+	return profiles, nil
+}
+
+// ErrUnknownUserDataDir is returned when the user data directory of the
+// browser can't be determined.
+var ErrUnknownUserDataDir = errors.New("unable to determine browser user data directory")
+
+// userDataDirs maps the browser name to the user data directory of its
+// stable release, relative to the OS-specific base directory.
+var userDataDirs = map[string]map[string]string{
+	"darwin": {
+		bChrome:   "Google/Chrome",
+		bChromium: "Chromium",
+		bBrave:    "BraveSoftware/Brave-Browser",
+		bEdge:     "Microsoft Edge",
+		bVivaldi:  "Vivaldi",
+		bOpera:    "com.operasoftware.Opera",
+	},
+	"linux": {
+		bChrome:    "google-chrome",
+		bChromium:  "chromium",
+		bBrave:     "BraveSoftware/Brave-Browser",
+		bEdge:      "microsoft-edge",
+		bVivaldi:   "vivaldi",
+		bOpera:     "opera",
+		bUngoogled: "chromium",
+	},
+	"windows": {
+		bChrome:    `Google\Chrome\User Data`,
+		bChromium:  `Chromium\User Data`,
+		bBrave:     `BraveSoftware\Brave-Browser\User Data`,
+		bEdge:      `Microsoft\Edge\User Data`,
+		bVivaldi:   `Vivaldi\User Data`,
+		bUngoogled: `Chromium\User Data`,
+	},
+}
+
+// channelDataDirs maps the browser name and the release channel to the user
+// data directory of the prerelease builds, relative to the OS-specific base
+// directory.
+var channelDataDirs = map[string]map[string]map[Channel]string{
+	"darwin": {
+		bChrome: {ChannelBeta: "Google/Chrome Beta", ChannelDev: "Google/Chrome Dev", ChannelCanary: "Google/Chrome Canary"},
+		bBrave:  {ChannelBeta: "BraveSoftware/Brave-Browser-Beta", ChannelDev: "BraveSoftware/Brave-Browser-Dev", ChannelCanary: "BraveSoftware/Brave-Browser-Nightly"},
+		bEdge:   {ChannelBeta: "Microsoft Edge Beta", ChannelDev: "Microsoft Edge Dev", ChannelCanary: "Microsoft Edge Canary"},
+		bOpera:  {ChannelBeta: "com.operasoftware.OperaNext", ChannelDev: "com.operasoftware.OperaDeveloper"},
+	},
+	"linux": {
+		bChrome: {ChannelBeta: "google-chrome-beta", ChannelDev: "google-chrome-unstable", ChannelCanary: "google-chrome-canary"},
+		bBrave:  {ChannelBeta: "BraveSoftware/Brave-Browser-Beta", ChannelDev: "BraveSoftware/Brave-Browser-Dev", ChannelCanary: "BraveSoftware/Brave-Browser-Nightly"},
+		bEdge:   {ChannelBeta: "microsoft-edge-beta", ChannelDev: "microsoft-edge-dev"},
+		bOpera:  {ChannelBeta: "opera-beta", ChannelDev: "opera-developer"},
+	},
+	"windows": {
+		bChrome: {ChannelBeta: `Google\Chrome Beta\User Data`, ChannelDev: `Google\Chrome Dev\User Data`, ChannelCanary: `Google\Chrome SxS\User Data`},
+		bBrave:  {ChannelBeta: `BraveSoftware\Brave-Browser-Beta\User Data`, ChannelDev: `BraveSoftware\Brave-Browser-Dev\User Data`, ChannelCanary: `BraveSoftware\Brave-Browser-Nightly\User Data`},
+		bEdge:   {ChannelBeta: `Microsoft\Edge Beta\User Data`, ChannelDev: `Microsoft\Edge Dev\User Data`, ChannelCanary: `Microsoft\Edge SxS\User Data`},
+	},
+}
+
+// userDataDir returns the default user data directory of the browser.  The
+// release channel and the packaging are detected by the executable path, if
+// not set.
+func userDataDir(b LocalBrowser) (string, error) {
+	var d baseDirs
+	d.home, _ = os.UserHomeDir()
+	switch runtime.GOOS {
+	case "windows":
+		d.local = os.Getenv("LocalAppData")
+	case "darwin":
+		if d.home != "" {
+			d.config = filepath.Join(d.home, "Library", "Application Support")
+		}
+	default:
+		d.config, _ = os.UserConfigDir()
+	}
+	return d.userDataDir(runtime.GOOS, b)
+}
+
+// baseDirs are the OS-specific base directories of the user data
+// directories.
+type baseDirs struct {
+	home   string // user home directory
+	config string // user configuration directory on Linux and macOS
+	local  string // %LocalAppData% on Windows
+}
+
+// userDataDir returns the user data directory of the browser b on goos.
+func (d baseDirs) userDataDir(goos string, b LocalBrowser) (string, error) {
+	rel, ok := userDataDirs[goos][b.Name]
+	if !ok {
+		return "", ErrUnknownUserDataDir
+	}
+	ch := b.Channel
+	if ch == "" {
+		ch = detectChannel(b.Path)
+	}
+	base := d.config
+	switch ch {
+	case ChannelStable:
+	case ChannelFlatpak:
+		// flatpak applications keep their configuration in the sandbox.
+		id := flatpakID(b.Path)
+		if id == "" || d.home == "" {
+			return "", ErrUnknownUserDataDir
+		}
+		base = filepath.Join(d.home, ".var", "app", id, "config")
+	case ChannelSnap:
+		name := snapName(b.Path)
+		if name == "" || d.home == "" {
+			return "", ErrUnknownUserDataDir
+		}
+		if name == "chromium" {
+			return filepath.Join(d.home, "snap", "chromium", "common", "chromium"), nil
+		}
+		base = filepath.Join(d.home, "snap", name, "current", ".config")
+	default:
+		if rel, ok = channelDataDirs[goos][b.Name][ch]; !ok {
+			return "", ErrUnknownUserDataDir
+		}
+	}
+	if goos == "windows" {
+		base = d.local
+	}
+	if base == "" {
+		return "", ErrUnknownUserDataDir
+	}
+	return filepath.Join(base, rel), nil
+}
+
+// flatpakID returns the flatpak application ID by the path of its exported
+// launcher, or the path inside the application directory.
+func flatpakID(path string) string {
+	p := filepath.ToSlash(path)
+	if _, after, ok := strings.Cut(p, "/flatpak/app/"); ok {
+		id, _, _ := strings.Cut(after, "/")
+		return id
+	}
+	if strings.Contains(p, "/flatpak/exports/bin/") {
+		return filepath.Base(path)
+	}
+	return ""
+}
+
+// snapName returns the snap name by the path of its launcher in /snap/bin,
+// or the path inside the snap directory.
+func snapName(path string) string {
+	p := filepath.ToSlash(path)
+	if after, ok := strings.CutPrefix(p, "/snap/bin/"); ok {
+		name, _, _ := strings.Cut(after, ".") // i.e. chromium.chromedriver
+		return name
+	}
+	if after, ok := strings.CutPrefix(p, "/snap/"); ok {
+		name, _, _ := strings.Cut(after, "/")
+		return name
+	}
+	return ""
+}
+
+// browserName guesses the browser name by the executable path.
+func browserName(binpath string) string {
+	for _, b := range browserList {
+		if b.Path == binpath {
+			return b.Name
+		}
+	}
+	base := strings.ToLower(filepath.Base(binpath))
+	switch {
+	case strings.Contains(base, "ungoogled"):
+		return bUngoogled
+	case strings.Contains(base, "vivaldi"):
+		return bVivaldi
+	case strings.Contains(base, "opera"):
+		return bOpera
+	case strings.Contains(base, "brave"):
+		return bBrave
+	case strings.Contains(base, "edge"):
+		return bEdge
+	case strings.Contains(base, "chromium") || strings.Contains(strings.ToLower(binpath), "chromium"):
+		return bChromium
+	default:
+		return bChrome
+	}
+}
+
+// snapshotProfile copies the relevant parts of the profile from the user data
+// directory src to the directory dst.  Files that do not exist are skipped,
+// as well as the files that can't be read (i.e. locked by the running
+// browser), the latter are reported by the skipped function.
+func snapshotProfile(dst, src, profile string, skipped func(path string, err error)) error {
+	if profile == "" {
+		profile = defaultProfile
+	}
+	if fi, err := os.Stat(filepath.Join(src, profile)); err != nil {
+		return fmt.Errorf("profile %q: %w", profile, err)
+	} else if !fi.IsDir() {
+		return fmt.Errorf("profile %q is not a directory", profile)
+	}
+	for _, f := range snapshotFiles {
+		rel := filepath.FromSlash(strings.ReplaceAll(f, "%s", profile))
+		if err := copyTree(filepath.Join(dst, rel), filepath.Join(src, rel), skipped); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyTree copies the file or directory src to dst.
+func copyTree(dst, src string, skipped func(path string, err error)) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			skipped(path, err)
+			return nil
+		}
+		if skipFiles[d.Name()] {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o700)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if err := copyFile(target, path); err != nil {
+			skipped(path, err)
+		}
+		return nil
+	})
+}
+
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,174 @@
+package slackauth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mkfiles(t *testing.T, root string, files ...string) {
+	t.Helper()
+	for _, f := range files {
+		p := filepath.Join(root, filepath.FromSlash(f))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(f), 0o644))
+	}
+}
+
+func Test_snapshotProfile(t *testing.T) {
+	src := t.TempDir()
+	mkfiles(t, src,
+		"Local State",
+		"Default/Network/Cookies",
+		"Default/Local Storage/leveldb/000003.log",
+		"Default/Local Storage/leveldb/LOCK",
+		"Default/Login Data",
+		"Default/Preferences",
+		"Default/History",
+		"Default/Cache/data_0",
+		"Profile 2/Network/Cookies",
+		"SingletonLock",
+	)
+
+	t.Run("default profile", func(t *testing.T) {
+		dst := t.TempDir()
+		var skipped []string
+		err := snapshotProfile(dst, src, "", func(path string, err error) {
+			skipped = append(skipped, path)
+		})
+		require.NoError(t, err)
+		assert.Empty(t, skipped)
+
+		for _, want := range []string{
+			"Local State",
+			"Default/Network/Cookies",
+			"Default/Local Storage/leveldb/000003.log",
+			"Default/Login Data",
+			"Default/Preferences",
+		} {
+			data, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(want)))
+			if assert.NoError(t, err, want) {
+				assert.Equal(t, want, string(data))
+			}
+		}
+		for _, notWant := range []string{
+			"Default/Local Storage/leveldb/LOCK",
+			"Default/History",
+			"Default/Cache",
+			"Profile 2",
+			"SingletonLock",
+		} {
+			assert.NoFileExists(t, filepath.Join(dst, filepath.FromSlash(notWant)), notWant)
+			assert.NoDirExists(t, filepath.Join(dst, filepath.FromSlash(notWant)), notWant)
+		}
+	})
+	t.Run("other profile", func(t *testing.T) {
+		dst := t.TempDir()
+		err := snapshotProfile(dst, src, "Profile 2", func(string, error) {})
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dst, "Profile 2", "Network", "Cookies"))
+		assert.NoDirExists(t, filepath.Join(dst, "Default"))
+	})
+	t.Run("missing profile", func(t *testing.T) {
+		err := snapshotProfile(t.TempDir(), src, "Profile 3", func(string, error) {})
+		assert.Error(t, err)
+	})
+}
+
+func Test_browserName(t *testing.T) {
+	tests := []struct {
+		binpath string
+		want    string
+	}{
+		{"/usr/bin/brave-browser", bBrave},
+		{`C:\Program Files (x86)\Microsoft\Edge\Application\msedge.exe`, bEdge},
+		{"/snap/bin/chromium", bChromium},
+		{"/Applications/Chromium.app/Contents/MacOS/Chromium", bChromium},
+		{"/usr/bin/google-chrome-stable", bChrome},
+		{"/usr/bin/vivaldi-stable", bVivaldi},
+		{"/Applications/Opera.app/Contents/MacOS/Opera", bOpera},
+		{"/usr/bin/ungoogled-chromium", bUngoogled},
+		{"/opt/something/unknown", bChrome},
+	}
+	for _, tt := range tests {
+		t.Run(tt.binpath, func(t *testing.T) {
+			if got := browserName(tt.binpath); got != tt.want {
+				t.Errorf("browserName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_baseDirs_userDataDir(t *testing.T) {
+	const home = "/home/user"
+	d := baseDirs{home: home, config: home + "/.config", local: `C:\Users\user\AppData\Local`}
+	mac := baseDirs{home: "/Users/user", config: "/Users/user/Library/Application Support"}
+	tests := []struct {
+		name    string
+		goos    string
+		dirs    baseDirs
+		b       LocalBrowser
+		want    string
+		wantErr bool
+	}{
+		{"chrome", "linux", d, LocalBrowser{Name: bChrome, Path: "/usr/bin/google-chrome"}, home + "/.config/google-chrome", false},
+		{"chrome beta", "linux", d, LocalBrowser{Name: bChrome, Path: "/usr/bin/google-chrome-beta"}, home + "/.config/google-chrome-beta", false},
+		{"chrome dev", "linux", d, LocalBrowser{Name: bChrome, Path: "/usr/bin/google-chrome-unstable"}, home + "/.config/google-chrome-unstable", false},
+		{"brave nightly", "linux", d, LocalBrowser{Name: bBrave, Path: "/usr/bin/brave-browser-nightly"}, home + "/.config/BraveSoftware/Brave-Browser-Nightly", false},
+		{"brave beta", "linux", d, LocalBrowser{Name: bBrave, Path: "/usr/bin/brave-browser-beta"}, home + "/.config/BraveSoftware/Brave-Browser-Beta", false},
+		{"channel set", "linux", d, LocalBrowser{Name: bEdge, Path: "/opt/edge/msedge", Channel: ChannelBeta}, home + "/.config/microsoft-edge-beta", false},
+		{"flatpak", "linux", d, LocalBrowser{Name: bBrave, Path: "/var/lib/flatpak/exports/bin/com.brave.Browser"}, home + "/.var/app/com.brave.Browser/config/BraveSoftware/Brave-Browser", false},
+		{"flatpak user", "linux", d, LocalBrowser{Name: bChromium, Path: home + "/.local/share/flatpak/exports/bin/org.chromium.Chromium"}, home + "/.var/app/org.chromium.Chromium/config/chromium", false},
+		{"flatpak app", "linux", d, LocalBrowser{Name: bChrome, Path: "/var/lib/flatpak/app/com.google.Chrome/current/active/files/extra/chrome"}, home + "/.var/app/com.google.Chrome/config/google-chrome", false},
+		{"snap chromium", "linux", d, LocalBrowser{Name: bChromium, Path: "/snap/bin/chromium"}, home + "/snap/chromium/common/chromium", false},
+		{"snap brave", "linux", d, LocalBrowser{Name: bBrave, Path: "/snap/bin/brave"}, home + "/snap/brave/current/.config/BraveSoftware/Brave-Browser", false},
+		{"unknown channel", "linux", d, LocalBrowser{Name: bChromium, Path: "/usr/bin/chromium", Channel: ChannelCanary}, "", true},
+		{"unknown browser", "linux", d, LocalBrowser{Name: "Lynx", Path: "/usr/bin/lynx"}, "", true},
+		{"chrome beta mac", "darwin", mac, LocalBrowser{Name: bChrome, Path: "/Applications/Google Chrome Beta.app/Contents/MacOS/Google Chrome Beta"}, "/Users/user/Library/Application Support/Google/Chrome Beta", false},
+		{"brave nightly mac", "darwin", mac, LocalBrowser{Name: bBrave, Path: "/Applications/Brave Browser Nightly.app/Contents/MacOS/Brave Browser Nightly"}, "/Users/user/Library/Application Support/BraveSoftware/Brave-Browser-Nightly", false},
+		{"chrome windows", "windows", d, LocalBrowser{Name: bChrome, Path: `C:\Program Files\Google\Chrome\Application\chrome.exe`}, filepath.Join(d.local, `Google\Chrome\User Data`), false},
+		{"chrome canary windows", "windows", d, LocalBrowser{Name: bChrome, Path: `C:\Users\user\AppData\Local\Google\Chrome SxS\Application\chrome.exe`}, filepath.Join(d.local, `Google\Chrome SxS\User Data`), false},
+		{"edge beta windows", "windows", d, LocalBrowser{Name: bEdge, Path: `C:\Program Files (x86)\Microsoft\Edge Beta\Application\msedge.exe`}, filepath.Join(d.local, `Microsoft\Edge Beta\User Data`), false},
+		{"no base dir", "windows", baseDirs{}, LocalBrowser{Name: bChrome, Path: "chrome"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.dirs.userDataDir(tt.goos, tt.b)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnknownUserDataDir)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, filepath.FromSlash(tt.want), got)
+		})
+	}
+}
+
+func Test_readProfiles(t *testing.T) {
+	dir := t.TempDir()
+	const localStateJSON = `{
+  "profile": {
+    "info_cache": {
+      "Profile 2": {"name": "Work", "user_name": "me@example.com"},
+      "Default": {"name": "Person 1", "user_name": ""},
+      "Profile 1": {"name": "Home", "user_name": "me@gmail.com"}
+    }
+  }
+}`
+	mkfiles(t, dir, "Local State")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Local State"), []byte(localStateJSON), 0o644))
+
+	got, err := readProfiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []Profile{
+		{Dir: "Default", Name: "Person 1"},
+		{Dir: "Profile 1", Name: "Home", Email: "me@gmail.com"},
+		{Dir: "Profile 2", Name: "Work", Email: "me@example.com"},
+	}, got)
+
+	_, err = readProfiles(t.TempDir())
+	assert.Error(t, err)
+}
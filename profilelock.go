@@ -0,0 +1,50 @@
+package slackauth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// lockFile is the name of the lock file in the persistent profile directory.
+const lockFile = "slackauth.lock"
+
+// ErrProfileLocked is returned when the persistent profile directory is in
+// use by another process.
+var ErrProfileLocked = errors.New("profile directory is used by another process")
+
+// AccountProfileDir returns the path of the persistent profile directory
+// for the account in the user configuration directory, i.e.
+// "~/.config/slackauth/profiles/<account>" on Linux.  Account can be any
+// string that identifies the account, i.e. "workspace/email".  The
+// directory is not created.  See [WithProfileDir].
+func AccountProfileDir(account string) (string, error) {
+	cfg, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cfg, "slackauth", "profiles", sanitiseName(account)), nil
+}
+
+// sanitiseName replaces all characters that are not letters, digits or
+// hyphens with the underscore, so that the name can be used as a file name.
+func sanitiseName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if !isDNSLabelRune(r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// lockProfile creates the profile directory, if it does not exist, and
+// acquires the exclusive lock on it.  It returns the function that releases
+// the lock.  If the lock is held by another process, it returns
+// [ErrProfileLocked].
+func lockProfile(dir string) (unlock func() error, err error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return lockExclusive(filepath.Join(dir, lockFile))
+}
@@ -0,0 +1,26 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package slackauth
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// lockExclusive creates the lock file exclusively, and removes it on unlock.
+// Unlike other platforms, the stale lock file is not removed if the process
+// dies, and must be removed manually.
+func lockExclusive(name string) (func() error, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0o600)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil, ErrProfileLocked
+		}
+		return nil, err
+	}
+	f.Close()
+	return func() error {
+		return os.Remove(name)
+	}, nil
+}
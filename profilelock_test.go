@@ -0,0 +1,82 @@
+package slackauth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-rod/rod/lib/launcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_lockProfile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profile")
+
+	unlock, err := lockProfile(dir)
+	require.NoError(t, err)
+	assert.DirExists(t, dir)
+
+	_, err = lockProfile(dir)
+	assert.ErrorIs(t, err, ErrProfileLocked)
+
+	require.NoError(t, unlock())
+
+	unlock, err = lockProfile(dir)
+	require.NoError(t, err, "must be able to lock after unlock")
+	require.NoError(t, unlock())
+}
+
+func TestClient_lockDataDir(t *testing.T) {
+	tmp := t.TempDir()
+	bin := filepath.Join(tmp, "chrome")
+	require.NoError(t, os.WriteFile(bin, nil, 0o755))
+	dir := filepath.Join(tmp, "profile")
+	newClient := func() *Client {
+		return &Client{opts: options{localBrowser: bin, dataDir: dir, lg: discardLogger()}}
+	}
+	newLauncher := func(c *Client) func() (*launcher.Launcher, error) {
+		return func() (*launcher.Launcher, error) {
+			return c.newBrwsrLauncher(context.Background(), true)
+		}
+	}
+
+	c := newClient()
+	l, err := c.newBrwsrLauncher(context.Background(), true)
+	require.NoError(t, err)
+	assert.Equal(t, dir, l.Get("user-data-dir"))
+	_, err = c.newBrwsrLauncher(context.Background(), true)
+	require.NoError(t, err, "the client must reuse its own lock")
+
+	other := newClient()
+	_, err = other.controlURL(context.Background(), newLauncher(other))
+	assert.ErrorIs(t, err, ErrProfileLocked)
+	var berr ErrBrowser
+	assert.ErrorAs(t, err, &berr)
+
+	require.NoError(t, c.Close())
+	_, err = other.newBrwsrLauncher(context.Background(), true)
+	require.NoError(t, err, "must be able to lock after the client is closed")
+	require.NoError(t, other.Close())
+}
+
+func Test_sanitiseName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"workspace", "workspace"},
+		{"workspace/me@example.com", "workspace_me_example_com"},
+		{"../../etc", "______etc"},
+		{"Ünïcode", "_n_code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitiseName(tt.name); got != tt.want {
+				t.Errorf("sanitiseName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
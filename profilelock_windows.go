@@ -0,0 +1,34 @@
+package slackauth
+
+import (
+	"errors"
+	"syscall"
+)
+
+const errSharingViolation syscall.Errno = 32
+
+// lockExclusive opens the file without sharing, which prevents other
+// processes from opening it.  The handle is closed by the OS if the process
+// dies.
+func lockExclusive(name string) (func() error, error) {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(p,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		0, // no sharing
+		nil,
+		syscall.OPEN_ALWAYS,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0)
+	if err != nil {
+		if errors.Is(err, errSharingViolation) {
+			return nil, ErrProfileLocked
+		}
+		return nil, err
+	}
+	return func() error {
+		return syscall.CloseHandle(h)
+	}, nil
+}
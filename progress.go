@@ -0,0 +1,119 @@
+package slackauth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventType is the type of the login progress event.
+type EventType int
+
+const (
+	EventBrowserLaunched      EventType = iota + 1 // browser started or attached
+	EventPageLoaded                                // login page or link loaded
+	EventPasswordLogin                             // switched to the password login
+	EventCredentialsSubmitted                      // email and password submitted
+	EventChallengeRequested                        // Slack asked for the challenge code
+	EventRedirectClicked                           // "open in browser" redirect clicked
+	EventTokenCaptured                             // token intercepted
+	EventCookiesExtracted                          // cookies read from the browser
+	EventFailed                                    // login failed, see Event.Err
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventBrowserLaunched:
+		return "browser launched"
+	case EventPageLoaded:
+		return "page loaded"
+	case EventPasswordLogin:
+		return "switched to password login"
+	case EventCredentialsSubmitted:
+		return "credentials submitted"
+	case EventChallengeRequested:
+		return "challenge requested"
+	case EventRedirectClicked:
+		return "redirect clicked"
+	case EventTokenCaptured:
+		return "token captured"
+	case EventCookiesExtracted:
+		return "cookies extracted"
+	case EventFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event is the login progress event, see [WithProgress].
+type Event struct {
+	Type     EventType
+	Time     time.Time     // time of the event
+	Duration time.Duration // duration of the stage, that ended with the event
+	URL      string        // page URL, for EventPageLoaded
+	Cookies  int           // number of cookies, for EventCookiesExtracted
+	Err      error         // failure reason, for EventFailed
+}
+
+func (e Event) String() string {
+	s := fmt.Sprintf("%s (%s)", e.Type, e.Duration.Round(time.Millisecond))
+	switch {
+	case e.Err != nil:
+		s += ": " + e.Err.Error()
+	case e.URL != "":
+		s += ": " + e.URL
+	case e.Type == EventCookiesExtracted:
+		s += fmt.Sprintf(": %d cookies", e.Cookies)
+	}
+	return s
+}
+
+// progress reports the login progress events to the callback, and measures
+// the stage durations.  Zero value is usable, and reports nothing.
+type progress struct {
+	fn func(Event)
+
+	mu   sync.Mutex
+	last time.Time // time of the previous event or the start of the flow
+}
+
+// now returns the current time, it is a variable for testing.
+var now = time.Now
+
+// begin marks the start of the login flow.
+func (p *progress) begin() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.last = now()
+}
+
+// emit reports the event.  The callback calls are serialised, as the events
+// may come from the different goroutines.
+func (p *progress) emit(e Event) {
+	if p == nil || p.fn == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.Time = now()
+	if !p.last.IsZero() {
+		e.Duration = e.Time.Sub(p.last)
+	}
+	p.last = e.Time
+	p.fn(e)
+}
+
+func (p *progress) event(t EventType) {
+	p.emit(Event{Type: t})
+}
+
+// failed reports the failure, if err is not nil.
+func (p *progress) failed(err error) {
+	if err != nil {
+		p.emit(Event{Type: EventFailed, Err: err})
+	}
+}
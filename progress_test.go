@@ -0,0 +1,81 @@
+package slackauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rusq/slackauth/slackauthtest"
+)
+
+func TestEvent_String(t *testing.T) {
+	tests := []struct {
+		name string
+		e    Event
+		want string
+	}{
+		{"plain", Event{Type: EventTokenCaptured, Duration: 1500 * time.Millisecond}, "token captured (1.5s)"},
+		{"url", Event{Type: EventPageLoaded, Duration: time.Second, URL: "https://acme.slack.com/"}, "page loaded (1s): https://acme.slack.com/"},
+		{"cookies", Event{Type: EventCookiesExtracted, Cookies: 3}, "cookies extracted (0s): 3 cookies"},
+		{"failed", Event{Type: EventFailed, Err: ErrInvalidCredentials}, "failed (0s): invalid credentials"},
+		{"unknown", Event{Type: 42}, "EventType(42) (0s)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.e.String())
+		})
+	}
+}
+
+func Test_progress(t *testing.T) {
+	start := time.Date(2024, 10, 18, 12, 0, 0, 0, time.UTC)
+	clock := start
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+
+	var events []Event
+	p := &progress{fn: func(e Event) { events = append(events, e) }}
+	p.begin()
+	clock = clock.Add(2 * time.Second)
+	p.event(EventBrowserLaunched)
+	clock = clock.Add(500 * time.Millisecond)
+	p.failed(nil)
+	p.failed(errors.New("boom"))
+
+	require.Len(t, events, 2)
+	assert.Equal(t, Event{Type: EventBrowserLaunched, Time: start.Add(2 * time.Second), Duration: 2 * time.Second}, events[0])
+	assert.Equal(t, EventFailed, events[1].Type)
+	assert.Equal(t, 500*time.Millisecond, events[1].Duration)
+	assert.EqualError(t, events[1].Err, "boom")
+
+	t.Run("no callback", func(t *testing.T) {
+		var p *progress
+		p.begin()
+		p.event(EventFailed)
+		(&progress{}).event(EventFailed)
+	})
+}
+
+func TestE2E_progress(t *testing.T) {
+	srv := slackauthtest.NewServer(slackauthtest.WithChallengeCode(123456))
+	defer srv.Close()
+
+	var types []EventType
+	c := e2eClient(t, srv,
+		WithChallengeFunc(func(string) (int, error) { return 123456, nil }),
+		WithProgress(func(e Event) { types = append(types, e.Type) }),
+	)
+	_, _, err := c.Headless(e2eContext(t), slackauthtest.DefaultEmail, slackauthtest.DefaultPassword)
+	require.NoError(t, err)
+	require.NotEmpty(t, types)
+	assert.Equal(t, []EventType{EventBrowserLaunched, EventPageLoaded, EventCredentialsSubmitted, EventChallengeRequested}, types[:4])
+	assert.Equal(t, []EventType{EventTokenCaptured, EventCookiesExtracted}, types[len(types)-2:])
+
+	types = nil
+	_, _, err = c.Headless(e2eContext(t), slackauthtest.DefaultEmail, "wrong")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.Equal(t, EventFailed, types[len(types)-1])
+}
@@ -0,0 +1,100 @@
+package slackauth
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// ErrProxyAuthUnsupported is returned when the SOCKS5 proxy URL has the
+// credentials, as the browser does not support SOCKS5 authentication.
+var ErrProxyAuthUnsupported = errors.New("browser does not support SOCKS5 proxy authentication")
+
+// parseProxy parses and validates the proxy URL.
+func parseProxy(proxyURL string) (*url.URL, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "socks5", "socks5h":
+		if u.User != nil {
+			return nil, ErrProxyAuthUnsupported
+		}
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy host is empty: %q", proxyURL)
+	}
+	return u, nil
+}
+
+// proxyServer returns the value for the browser --proxy-server flag, i.e.
+// the proxy URL without the credentials.  Chrome resolves the host names on
+// the SOCKS5 proxy side, so "socks5h" is the same as "socks5".
+func proxyServer(u *url.URL) string {
+	scheme := u.Scheme
+	if scheme == "socks5h" {
+		scheme = "socks5"
+	}
+	return scheme + "://" + u.Host
+}
+
+// proxyFlags sets the browser proxy flags.
+func proxyFlags(l *launcher.Launcher, u *url.URL) *launcher.Launcher {
+	if u == nil {
+		return l
+	}
+	return l.Proxy(proxyServer(u))
+}
+
+// handleProxyAuth answers the proxy authentication challenges with the
+// credentials from the proxy URL through the Fetch domain, so that the
+// browser does not show the authentication prompt.  Each request is answered
+// once, if the proxy rejects the credentials, the authentication is
+// cancelled.  It does nothing if the proxy has no credentials.
+func (c *Client) handleProxyAuth(b *rod.Browser) error {
+	u := c.opts.proxy
+	if u == nil || u.User == nil || c.opts.isAttached() {
+		return nil
+	}
+	username := u.User.Username()
+	password, _ := u.User.Password()
+
+	// the events are handled sequentially, no locking is needed.
+	answered := make(map[proto.FetchRequestID]bool)
+	wait := b.EachEvent(
+		func(e *proto.FetchRequestPaused) {
+			_ = proto.FetchContinueRequest{RequestID: e.RequestID}.Call(b)
+		},
+		func(e *proto.FetchAuthRequired) {
+			resp := &proto.FetchAuthChallengeResponse{
+				Response: proto.FetchAuthChallengeResponseResponseDefault,
+			}
+			switch {
+			case e.AuthChallenge == nil || e.AuthChallenge.Source != proto.FetchAuthChallengeSourceProxy:
+				// not ours, let the browser handle it.
+			case answered[e.RequestID]:
+				c.opts.lg.Warn("proxy rejected the credentials", "proxy", u.Host)
+				resp.Response = proto.FetchAuthChallengeResponseResponseCancelAuth
+			default:
+				answered[e.RequestID] = true
+				resp.Response = proto.FetchAuthChallengeResponseResponseProvideCredentials
+				resp.Username = username
+				resp.Password = password
+			}
+			_ = proto.FetchContinueWithAuth{RequestID: e.RequestID, AuthChallengeResponse: resp}.Call(b)
+		},
+	)
+	if err := (proto.FetchEnable{HandleAuthRequests: true}).Call(b); err != nil {
+		return ErrBrowser{Err: err, FailedTo: "enable proxy authentication"}
+	}
+	go wait()
+	return nil
+}
@@ -0,0 +1,301 @@
+package slackauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// redacted replaces the secret values.
+const redacted = "[REDACTED]"
+
+// reSlackToken matches the Slack tokens and the "d" cookie values, i.e.
+// "xoxc-..." and "xoxd-...", including the URL-encoded ones.
+var reSlackToken = regexp.MustCompile(`xox[a-z]-[A-Za-z0-9%/+=._-]+`)
+
+// reLoginLink matches the secret part of the magic login link path, i.e.
+// "z-app-610187951300-9981196591425-e95b3883...".
+var reLoginLink = regexp.MustCompile(`z-app-[A-Za-z0-9-]+`)
+
+// loginLinkRedacted replaces the secret part of the magic login link.
+const loginLinkRedacted = "z-app-" + redacted
+
+// sensitiveNames are the names of the form fields and query parameters, that
+// hold the secrets.
+var sensitiveNames = map[string]bool{
+	"token":    true,
+	"password": true,
+	"passwd":   true,
+	"pwd":      true,
+	"d":        true, // session cookie
+}
+
+// isSensitive returns true if the form field or parameter name holds the
+// secret.
+func isSensitive(name string) bool {
+	return sensitiveNames[strings.ToLower(name)]
+}
+
+// redactString replaces the Slack tokens and the magic login links in s.
+func redactString(s string) string {
+	s = reSlackToken.ReplaceAllString(s, redacted)
+	return reLoginLink.ReplaceAllString(s, loginLinkRedacted)
+}
+
+// redactURL replaces the values of the sensitive query parameters, the
+// Slack tokens and the magic login link secret in the URL.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.RawQuery == "" {
+		return redactString(s)
+	}
+	q := u.Query()
+	changed := false
+	for name := range q {
+		if isSensitive(name) {
+			q[name] = []string{redacted}
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = q.Encode()
+	}
+	return redactString(u.String())
+}
+
+// minSecretLen is the minimum length of the known secret, the shorter
+// values would redact the unrelated text.
+const minSecretLen = 4
+
+// secrets is the set of the secret values known to the client: the
+// password, the token and the session cookie.  They are replaced in the
+// logs, the errors and the failure artifacts, along with anything, that
+// looks like the Slack token.  Nil secrets only replace the tokens.
+type secrets struct {
+	mu     sync.RWMutex
+	values []string // the secrets and their encoded forms, longest first
+}
+
+// add adds the secret values.  The URL, JSON and HTML encoded forms of the
+// values are added as well, as the values appear in the form data, the
+// page scripts and the markup.
+func (s *secrets) add(values ...string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range values {
+		if len(v) < minSecretLen {
+			continue
+		}
+		for _, enc := range encodings(v) {
+			if !slices.Contains(s.values, enc) {
+				s.values = append(s.values, enc)
+			}
+		}
+	}
+	// the longer values first, so that the value is replaced before its
+	// substring.
+	slices.SortStableFunc(s.values, func(a, b string) int { return len(b) - len(a) })
+}
+
+// encodings returns v and the forms it takes in the URLs, form data, JSON,
+// quoted strings and HTML.
+func encodings(v string) []string {
+	return []string{
+		v,
+		url.QueryEscape(v),
+		url.PathEscape(v),
+		jsonEscape(v, true),
+		jsonEscape(v, false),
+		unquote(strconv.Quote(v)),
+		html.EscapeString(v),
+	}
+}
+
+// jsonEscape returns v as it appears in the JSON string.
+func jsonEscape(v string, escapeHTML bool) string {
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(escapeHTML)
+	if err := enc.Encode(v); err != nil {
+		return v
+	}
+	return unquote(strings.TrimSuffix(buf.String(), "\n"))
+}
+
+// unquote strips the double quotes around s.
+func unquote(s string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(s, `"`), `"`)
+}
+
+// addCookies adds the session cookie values.
+func (s *secrets) addCookies(cookies []*http.Cookie) {
+	for _, c := range cookies {
+		if isSensitive(c.Name) || strings.HasPrefix(c.Name, "d-") {
+			s.add(c.Value)
+		}
+	}
+}
+
+// redact replaces the known secrets and the Slack tokens in str.
+func (s *secrets) redact(str string) string {
+	if s != nil {
+		s.mu.RLock()
+		for _, v := range s.values {
+			str = strings.ReplaceAll(str, v, redacted)
+		}
+		s.mu.RUnlock()
+	}
+	return redactString(str)
+}
+
+// reBase64 matches the JSON strings, that may hold the base64 encoded data,
+// i.e. the request bodies in the DevTools protocol messages.
+var reBase64 = regexp.MustCompile(`"([A-Za-z0-9+/]{16,}={0,2})"`)
+
+// redactJSON replaces the known secrets and the Slack tokens in the raw
+// JSON, including the base64 encoded strings.  The rest of the data is left
+// as is, so that the redacted messages can be compared.
+func (s *secrets) redactJSON(data []byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+	data = reBase64.ReplaceAllFunc(data, func(b []byte) []byte {
+		raw, err := base64.StdEncoding.DecodeString(string(b[1 : len(b)-1]))
+		if err != nil {
+			return b
+		}
+		if r := s.redact(string(raw)); r != string(raw) {
+			return []byte(`"` + base64.StdEncoding.EncodeToString([]byte(r)) + `"`)
+		}
+		return b
+	})
+	return []byte(s.redact(string(data)))
+}
+
+// redactErr returns err, if its message has no secrets.  Otherwise, the
+// errors of this package are rebuilt with the redacted cause, and other
+// errors are wrapped, so that [errors.Is] and [errors.As] keep working.
+func (s *secrets) redactErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if s.redact(msg) == msg {
+		return err
+	}
+	switch e := err.(type) {
+	case ErrBrowser:
+		e.Err = s.redactErr(e.Err)
+		return e
+	case ErrArtifacts:
+		e.Err = s.redactErr(e.Err)
+		return e
+	}
+	return &redactedError{msg: s.redact(msg), err: err}
+}
+
+// redactedError is the error with the secrets removed from the message.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// failed redacts the login error and reports the failure, it is called
+// when the login flow returns.
+func (c *Client) failed(err error) error {
+	if err == nil {
+		return nil
+	}
+	err = c.secrets.redactErr(err)
+	c.prog.failed(err)
+	return err
+}
+
+// redactHandler is the slog handler, that replaces the secrets in the
+// messages and the attribute values.
+type redactHandler struct {
+	h slog.Handler
+	s *secrets
+}
+
+func newRedactHandler(h slog.Handler, s *secrets) slog.Handler {
+	return &redactHandler{h: h, s: s}
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.h.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, h.s.redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		nr.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.h.Handle(ctx, nr)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	ra := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		ra[i] = h.redactAttr(a)
+	}
+	return &redactHandler{h: h.h.WithAttrs(ra), s: h.s}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{h: h.h.WithGroup(name), s: h.s}
+}
+
+// redactAttr replaces the values of the sensitive keys, and the secrets in
+// the other values.
+func (h *redactHandler) redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	switch {
+	case a.Value.Kind() == slog.KindGroup:
+		group := a.Value.Group()
+		ra := make([]any, len(group))
+		for i, ga := range group {
+			ra[i] = h.redactAttr(ga)
+		}
+		return slog.Group(a.Key, ra...)
+	case isSensitive(a.Key) || strings.EqualFold(a.Key, "cookie") || strings.EqualFold(a.Key, "cookies"):
+		return slog.String(a.Key, redacted)
+	case a.Value.Kind() == slog.KindString:
+		return slog.String(a.Key, h.s.redact(a.Value.String()))
+	case a.Value.Kind() == slog.KindAny:
+		v := a.Value.Any()
+		if err, ok := v.(error); ok {
+			if rerr := h.s.redactErr(err); rerr != err {
+				return slog.Any(a.Key, rerr)
+			}
+			return a
+		}
+		s := fmt.Sprint(v)
+		if rs := h.s.redact(s); rs != s {
+			return slog.String(a.Key, rs)
+		}
+	}
+	return a
+}
@@ -0,0 +1,244 @@
+package slackauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/go-rod/rod"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rusq/slackauth/slackauthtest"
+)
+
+func Test_redactURL(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"no query", "https://acme.slack.com/client/T0123", "https://acme.slack.com/client/T0123"},
+		{"sensitive param", "https://acme.slack.com/api/x?a=1&token=secret", "https://acme.slack.com/api/x?a=1&token=%5BREDACTED%5D"},
+		{"token in path", "https://acme.slack.com/ssb/xoxc-1-2-3", "https://acme.slack.com/ssb/[REDACTED]"},
+		{"relative", "/client?pwd=x", "/client?pwd=%5BREDACTED%5D"},
+		{
+			"login link",
+			"https://app.slack.com/t/acme/login/z-app-610187951300-9981196591425-e95b38836efcfc97?src=qr_code&user_id=U1",
+			"https://app.slack.com/t/acme/login/z-app-[REDACTED]?src=qr_code&user_id=U1",
+		},
+		{"login link with token", "https://acme.slack.com/z-app-T0123-4567/redeem?token=x", "https://acme.slack.com/z-app-[REDACTED]/redeem?token=%5BREDACTED%5D"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redactURL(tt.in))
+		})
+	}
+}
+
+func Test_redactString(t *testing.T) {
+	assert.Equal(t, "token [REDACTED], cookie [REDACTED]", redactString("token xoxc-123-abc, cookie xoxd-a%2Fb%3D"))
+}
+
+const testSecret = `p@ss w0rd"<&`
+
+func Test_secrets_redact(t *testing.T) {
+	var s secrets
+	s.add(testSecret, "abc") // too short, ignored
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "typed " + testSecret, "typed [REDACTED]"},
+		{"form", "password=" + url.QueryEscape(testSecret) + "&remember=1", "password=[REDACTED]&remember=1"},
+		{"path", "/x/" + url.PathEscape(testSecret), "/x/[REDACTED]"},
+		{"json", `{"password":"p@ss w0rd\"<&"}`, `{"password":"[REDACTED]"}`},
+		{"html", `<input value="p@ss w0rd&#34;&lt;&amp;">`, `<input value="[REDACTED]">`},
+		{"token", "token xoxc-1-2-3", "token [REDACTED]"},
+		{"short value", "abc", "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, s.redact(tt.in))
+		})
+	}
+	t.Run("nil", func(t *testing.T) {
+		var s *secrets
+		s.add(testSecret)
+		assert.Equal(t, testSecret+" [REDACTED]", s.redact(testSecret+" xoxd-abc"))
+	})
+}
+
+func Test_secrets_redactJSON(t *testing.T) {
+	var s secrets
+	s.add(testSecret)
+	body := base64.StdEncoding.EncodeToString([]byte("token=xoxc-1-2-3&remember=1"))
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"empty", "", ""},
+		{"string", `{"text":"p@ss w0rd\"\u003c\u0026"}`, `{"text":"[REDACTED]"}`},
+		{"token", `{"postData":"token=xoxc-1-2-3"}`, `{"postData":"token=[REDACTED]"}`},
+		{"base64", `{"bytes":"` + body + `"}`, `{"bytes":"` + base64.StdEncoding.EncodeToString([]byte("token=[REDACTED]&remember=1")) + `"}`},
+		{"not base64", `{"targetId":"654FBA6A292B11105E5BF7993702FEF2"}`, `{"targetId":"654FBA6A292B11105E5BF7993702FEF2"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(s.redactJSON([]byte(tt.in))))
+		})
+	}
+}
+
+func Test_secrets_addCookies(t *testing.T) {
+	var s secrets
+	s.addCookies([]*http.Cookie{
+		{Name: "d", Value: "session-value"},
+		{Name: "d-s", Value: "1700000000"},
+		{Name: "b", Value: "browser-id"},
+	})
+	assert.Equal(t, "[REDACTED] [REDACTED] browser-id", s.redact("session-value 1700000000 browser-id"))
+}
+
+func Test_secrets_redactErr(t *testing.T) {
+	var s secrets
+	s.add(testSecret)
+
+	clean := errors.New("no secrets here")
+	assert.Same(t, clean, s.redactErr(clean))
+	assert.Nil(t, s.redactErr(nil))
+
+	cause := fmt.Errorf("typing %q: %w", testSecret, context.Canceled)
+	err := s.redactErr(ErrArtifacts{Err: ErrBrowser{Err: cause, FailedTo: "fill in password field"}, Dir: "/tmp/x"})
+	assert.NotContains(t, err.Error(), "w0rd")
+	assert.ErrorIs(t, err, context.Canceled)
+	var berr ErrBrowser
+	require.ErrorAs(t, err, &berr)
+	assert.Equal(t, "fill in password field", berr.FailedTo)
+	assert.NotContains(t, berr.Error(), "w0rd")
+}
+
+func TestErrBrowser_Error(t *testing.T) {
+	err := ErrBrowser{Err: errors.New("bad form token=xoxc-123-456"), FailedTo: "submit"}
+	assert.Equal(t, "browser automation error: failed to submit: bad form token=[REDACTED]", err.Error())
+}
+
+func Test_redactHandler(t *testing.T) {
+	var s secrets
+	s.add(testSecret)
+	var buf bytes.Buffer
+	lg := slog.New(newRedactHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}), &s))
+
+	lg = lg.With("preset", "x "+testSecret).WithGroup("g")
+	lg.Debug("message "+testSecret,
+		"password", "hunter22",
+		"cookie", "d=abc",
+		"text", "has "+testSecret,
+		"err", fmt.Errorf("wrapped: %w", errors.New(testSecret)),
+		"any", struct{ Token string }{"xoxc-1-2"},
+		slog.Group("nested", "text", testSecret, "n", 42),
+	)
+	out := buf.String()
+	for _, leak := range []string{"w0rd", "hunter22", "d=abc", "xoxc-"} {
+		assert.NotContains(t, out, leak)
+	}
+	assert.Contains(t, out, "g.nested.n=42")
+	assert.Contains(t, out, "g.password=[REDACTED]")
+}
+
+func Test_rodLogger_redact(t *testing.T) {
+	var buf bytes.Buffer
+	lg := rodLogger{slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+	lg.Println(rod.TraceTypeInput, "insert text hunter22", "page")
+	lg.Println(rod.TraceTypeInput, "press key: KeyH", "page")
+	lg.Println(rod.TraceTypeInput, "left click", "element")
+	out := buf.String()
+	assert.NotContains(t, out, "hunter22")
+	assert.NotContains(t, out, "KeyH")
+	assert.Contains(t, out, "insert text [REDACTED]")
+	assert.Contains(t, out, "left click")
+}
+
+func TestNewContext_redactLogs(t *testing.T) {
+	var buf bytes.Buffer
+	c, err := New("acme",
+		WithSkipWorkspaceCheck(),
+		WithSlog(slog.New(slog.NewTextHandler(&buf, nil))),
+		WithCookie(&http.Cookie{Name: "d", Value: "xoxd-session"}),
+	)
+	require.NoError(t, err)
+	c.secrets.add("captured-token")
+	c.opts.lg.Info("debugging", "value", "xoxd-session and captured-token")
+	assert.NotContains(t, buf.String(), "xoxd-session")
+	assert.NotContains(t, buf.String(), "captured-token")
+}
+
+func TestE2E_redact(t *testing.T) {
+	srv := slackauthtest.NewServer()
+	defer srv.Close()
+	var buf syncBuffer
+	dir := t.TempDir()
+	c := e2eClient(t, srv,
+		WithSlog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		WithDebug(true),
+		WithBrowserFlags(map[string]string{"headless": ""}),
+		WithArtifactsDir(dir),
+	)
+	const wrong = "wrong-password"
+	_, _, err := c.Headless(e2eContext(t), slackauthtest.DefaultEmail, wrong)
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.NotContains(t, err.Error(), wrong)
+
+	token, cookies, err := c.Headless(e2eContext(t), slackauthtest.DefaultEmail, slackauthtest.DefaultPassword)
+	require.NoError(t, err)
+	c.opts.lg.Debug("after login", "token", token, "cookies", cookies, "text", "token is "+token)
+
+	leaks := []string{wrong, slackauthtest.DefaultPassword, slackauthtest.DefaultToken, slackauthtest.DefaultCookie}
+	out := buf.String()
+	assert.Contains(t, out, "msg=rod trace=", "debug traces must be logged")
+	for _, leak := range leaks {
+		assert.NotContains(t, out, leak)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, f := range files {
+		if filepath.Ext(f) == ".png" {
+			continue
+		}
+		data, err := os.ReadFile(f)
+		require.NoError(t, err)
+		for _, leak := range leaks {
+			assert.NotContains(t, string(data), leak, f)
+		}
+	}
+}
+
+// syncBuffer is the buffer, that is safe for the concurrent writes.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,121 @@
+package slackauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Selectors are the CSS selectors of the Slack login page elements, that the
+// automated login interacts with.  When Slack changes the login pages, the
+// selectors can be patched with [WithSelectors] or [WithSelectorsFile]
+// without waiting for the new release.  Empty fields keep the default
+// values, see [DefaultSelectors].
+type Selectors struct {
+	// PasswordLogin is the link that switches the email login page to the
+	// password login.
+	PasswordLogin string `json:"password_login,omitempty" yaml:"password_login,omitempty"`
+	// Email is the email input field.
+	Email string `json:"email,omitempty" yaml:"email,omitempty"`
+	// Password is the password input field.
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// AnyError is any error on the sign in page.
+	AnyError string `json:"any_error,omitempty" yaml:"any_error,omitempty"`
+	// PasswordError is the invalid credentials error.
+	PasswordError string `json:"password_error,omitempty" yaml:"password_error,omitempty"`
+	// SignInAlertText is the text of the sign in error message.
+	SignInAlertText string `json:"sign_in_alert_text,omitempty" yaml:"sign_in_alert_text,omitempty"`
+	// Redirect is the "open in browser" link on the redirect page.
+	Redirect string `json:"redirect,omitempty" yaml:"redirect,omitempty"`
+	// UnknownBrowser is the root of the challenge code page, that is shown
+	// to the unknown browsers.
+	UnknownBrowser string `json:"unknown_browser,omitempty" yaml:"unknown_browser,omitempty"`
+	// DigitN is the format of the challenge code digit input field, it must
+	// contain one %d verb for the digit number, starting with 1.
+	DigitN string `json:"digit_n,omitempty" yaml:"digit_n,omitempty"`
+	// CodeError is the invalid challenge code error.
+	CodeError string `json:"code_error,omitempty" yaml:"code_error,omitempty"`
+}
+
+// DefaultSelectors returns the selectors of the current Slack login pages.
+func DefaultSelectors() Selectors {
+	return Selectors{
+		PasswordLogin:   idPasswordLogin,
+		Email:           idEmail,
+		Password:        idPassword,
+		AnyError:        idAnyError,
+		PasswordError:   idPasswordError,
+		SignInAlertText: idSignInAlertText,
+		Redirect:        idRedirect,
+		UnknownBrowser:  idUnknownBrowser,
+		DigitN:          idDigitN,
+		CodeError:       idCodeError,
+	}
+}
+
+// merge returns s with the non-empty fields of over.
+func (s Selectors) merge(over Selectors) Selectors {
+	dst := reflect.ValueOf(&s).Elem()
+	src := reflect.ValueOf(over)
+	for i := range src.NumField() {
+		if v := src.Field(i).String(); v != "" {
+			dst.Field(i).SetString(v)
+		}
+	}
+	return s
+}
+
+// validate checks that the selectors are usable.
+func (s Selectors) validate() error {
+	if s.DigitN != "" && strings.Count(s.DigitN, "%d") != 1 {
+		return fmt.Errorf("digit_n selector must contain one %%d verb, got %q", s.DigitN)
+	}
+	return nil
+}
+
+// LoadSelectors loads the selectors from the JSON or YAML file, the format
+// is determined by the file extension (".yaml", ".yml" or anything else for
+// JSON).  Unknown keys are rejected to catch typos, and the keys that are
+// not present keep the default values.
+func LoadSelectors(path string) (Selectors, error) {
+	s, err := readSelectors(path)
+	if err != nil {
+		return Selectors{}, err
+	}
+	return DefaultSelectors().merge(s), nil
+}
+
+// readSelectors reads the selectors from the file, the keys that are not
+// present are left empty.
+func readSelectors(path string) (Selectors, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Selectors{}, err
+	}
+	var s Selectors
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&s)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&s)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return Selectors{}, fmt.Errorf("invalid selectors file %s: %w", path, err)
+	}
+	if err := s.validate(); err != nil {
+		return Selectors{}, fmt.Errorf("invalid selectors file %s: %w", path, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,139 @@
+package slackauth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectors_merge(t *testing.T) {
+	def := DefaultSelectors()
+	tests := []struct {
+		name string
+		over Selectors
+		want Selectors
+	}{
+		{"empty", Selectors{}, def},
+		{
+			"partial",
+			Selectors{Email: "#login_email", DigitN: `[name="d%d"]`},
+			func() Selectors {
+				s := def
+				s.Email = "#login_email"
+				s.DigitN = `[name="d%d"]`
+				return s
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, def.merge(tt.over))
+		})
+	}
+}
+
+func TestSelectors_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       Selectors
+		wantErr bool
+	}{
+		{"defaults", DefaultSelectors(), false},
+		{"empty", Selectors{}, false},
+		{"no verb", Selectors{DigitN: `[aria-label="digit"]`}, true},
+		{"two verbs", Selectors{DigitN: `[aria-label="digit %d of %d"]`}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.s.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSelectors(t *testing.T) {
+	patched := DefaultSelectors()
+	patched.Password = "#pwd"
+	patched.Redirect = `[data-qa="open_in_browser"]`
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    Selectors
+		wantErr bool
+	}{
+		{
+			name:    "json",
+			file:    "selectors.json",
+			content: `{"password": "#pwd", "redirect": "[data-qa=\"open_in_browser\"]"}`,
+			want:    patched,
+		},
+		{
+			name:    "yaml",
+			file:    "selectors.yaml",
+			content: "password: '#pwd'\nredirect: '[data-qa=\"open_in_browser\"]'\n",
+			want:    patched,
+		},
+		{
+			name:    "empty file",
+			file:    "selectors.yml",
+			content: "",
+			want:    DefaultSelectors(),
+		},
+		{
+			name:    "unknown json key",
+			file:    "selectors.json",
+			content: `{"passwrod": "#pwd"}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown yaml key",
+			file:    "selectors.yaml",
+			content: "passwrod: '#pwd'\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid digit_n",
+			file:    "selectors.json",
+			content: `{"digit_n": "[aria-label=\"digit\"]"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o644))
+			got, err := LoadSelectors(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadSelectors(filepath.Join(t.TempDir(), "missing.json"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestWithSelectorsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "selectors.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"email": "#login_email"}`), 0o644))
+
+	o := options{selectors: DefaultSelectors()}
+	o.apply([]Option{WithSelectors(Selectors{Password: "#pwd"}), WithSelectorsFile(path)})
+	require.NoError(t, o.err)
+	assert.Equal(t, "#login_email", o.selectors.Email)
+	assert.Equal(t, "#pwd", o.selectors.Password, "file must not reset the earlier overrides")
+	assert.Equal(t, idRedirect, o.selectors.Redirect)
+
+	o.apply([]Option{WithSelectors(Selectors{DigitN: "#digit"})})
+	assert.Error(t, o.err)
+}
@@ -1,6 +1,7 @@
 package slackauth
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	reflect "reflect"
@@ -11,10 +12,35 @@ import (
 
 func Test_checkWorkspaceURL(t *testing.T) {
 	t.Parallel()
-	t.Run("error", func(t *testing.T) {
+	t.Run("network error", func(t *testing.T) {
 		t.Parallel()
-		err := checkWorkspaceURL("http://127.0.0.1:9999")
-		assert.ErrorIs(t, err, ErrWorkspaceNotFound)
+		err := checkWorkspaceURL(context.Background(), http.DefaultClient, "http://127.0.0.1:9999")
+		var cerr ErrWorkspaceCheck
+		assert.ErrorAs(t, err, &cerr)
+		assert.NotErrorIs(t, err, ErrWorkspaceNotFound)
+	})
+	t.Run("server error", func(t *testing.T) {
+		t.Parallel()
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+		err := checkWorkspaceURL(context.Background(), http.DefaultClient, srv.URL)
+		var cerr ErrWorkspaceCheck
+		if assert.ErrorAs(t, err, &cerr) {
+			assert.Equal(t, http.StatusServiceUnavailable, cerr.StatusCode)
+		}
+	})
+	t.Run("cancelled context", func(t *testing.T) {
+		t.Parallel()
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := checkWorkspaceURL(ctx, http.DefaultClient, srv.URL)
+		assert.ErrorIs(t, err, context.Canceled)
 	})
 	t.Run("404", func(t *testing.T) {
 		t.Parallel()
@@ -22,7 +48,7 @@ func Test_checkWorkspaceURL(t *testing.T) {
 			http.NotFound(w, r)
 		}))
 		defer srv.Close()
-		err := checkWorkspaceURL(srv.URL)
+		err := checkWorkspaceURL(context.Background(), http.DefaultClient, srv.URL)
 		assert.ErrorIs(t, err, ErrWorkspaceNotFound)
 	})
 	t.Run("ok", func(t *testing.T) {
@@ -31,53 +57,42 @@ func Test_checkWorkspaceURL(t *testing.T) {
 			w.WriteHeader(http.StatusOK)
 		}))
 		defer srv.Close()
-		err := checkWorkspaceURL(srv.URL)
+		err := checkWorkspaceURL(context.Background(), http.DefaultClient, srv.URL)
 		assert.NoError(t, err)
 	})
 }
 
-func Test_isURLSafe(t *testing.T) {
-	type args struct {
-		s string
-	}
-	tests := []struct {
-		name string
-		args args
-		want bool
-	}{
-		{
-			name: "empty",
-			args: args{s: ""},
-			want: true,
-		},
-		{
-			name: "safe",
-			args: args{s: "abcABC123-_.~"},
-			want: true,
-		},
-		{
-			name: "unsafe",
-			args: args{s: "abcABC123-_.~!@#$%^&*()"},
-			want: false,
-		},
-		{
-			name: "unicode",
-			args: args{s: "🍌"},
-			want: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := isURLSafe(tt.args.s); got != tt.want {
-				t.Errorf("isURLSafe() = %v, want %v", got, tt.want)
-			}
-		})
-	}
+func TestNewContext(t *testing.T) {
+	t.Run("skips the check", func(t *testing.T) {
+		hc := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			t.Fatal("unexpected request")
+			return nil, nil
+		})}
+		_, err := NewContext(context.Background(), "test", WithHTTPClient(hc), WithSkipWorkspaceCheck())
+		assert.NoError(t, err)
+	})
+	t.Run("uses the http client", func(t *testing.T) {
+		var got string
+		hc := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			got = r.Method + " " + r.URL.String()
+			return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody, Request: r}, nil
+		})}
+		_, err := NewContext(context.Background(), "test", WithHTTPClient(hc))
+		assert.ErrorIs(t, err, ErrWorkspaceNotFound)
+		assert.Equal(t, "HEAD https://test.slack.com/", got)
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
 }
 
 func Test_filterCookies(t *testing.T) {
 	type args struct {
 		cookies []*http.Cookie
+		extra   []string
 	}
 	tests := []struct {
 		name string
@@ -103,10 +118,29 @@ func Test_filterCookies(t *testing.T) {
 				{Domain: ".endlessefforts.onelogin.com"},
 			},
 		},
+		{
+			name: "Retains custom domain and GovSlack",
+			args: args{
+				cookies: []*http.Cookie{
+					{Domain: ".acme.slack-gov.com"},
+					{Domain: "chat.example.com"},
+					{Domain: ".other.com"},
+					{Domain: ".notexample.com"},
+					{Domain: "example.com.evil.net"},
+					{Domain: ".example.com"},
+				},
+				extra: []string{"example.com"},
+			},
+			want: []*http.Cookie{
+				{Domain: ".acme.slack-gov.com"},
+				{Domain: "chat.example.com"},
+				{Domain: ".example.com"},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := filterCookies(tt.args.cookies); !reflect.DeepEqual(got, tt.want) {
+			if got := filterCookies(tt.args.cookies, tt.args.extra...); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("filterCookies() = %v, want %v", got, tt.want)
 			}
 		})
@@ -0,0 +1,104 @@
+package slackauthtest
+
+import "html/template"
+
+// The pages below carry only the elements and attributes that slackauth
+// interacts with, they must be kept in sync with the selectors in
+// login_auto.go.
+
+var tmSignIn = template.Must(template.New("sign_in").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Sign in | Slack</title></head>
+<body>
+{{- if .Error}}
+<div data-qa-error="true" class="c-inline_alert">
+{{- if .BadCreds}}<div id="password_error"></div>{{end}}
+<span class="c-inline_alert__text">{{.Error}}</span>
+</div>
+<script>console.warn("sign in failed:", {{.Error}});</script>
+{{- end}}
+<form id="signin_form" method="post" action="/sign_in_with_password">
+<input type="email" id="email" name="email">
+{{- if .Password}}
+<input type="password" id="password" name="password">
+<button type="submit" id="signin_btn">Sign In</button>
+{{- else}}
+<a href="/sign_in_with_password?with_password=1" data-qa="sign_in_password_link">sign in with a password instead</a>
+{{- end}}
+</form>
+</body>
+</html>
+`))
+
+var tmEnterCode = template.Must(template.New("enter_code").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Check your email | Slack</title></head>
+<body>
+<div id="enter_code_app_root">
+<div id="code_error"></div>
+<div id="digits">
+<input type="text" maxlength="1" aria-label="digit 1 of 6">
+<input type="text" maxlength="1" aria-label="digit 2 of 6">
+<input type="text" maxlength="1" aria-label="digit 3 of 6">
+<input type="text" maxlength="1" aria-label="digit 4 of 6">
+<input type="text" maxlength="1" aria-label="digit 5 of 6">
+<input type="text" maxlength="1" aria-label="digit 6 of 6">
+</div>
+</div>
+<script>
+const inputs = document.querySelectorAll("#digits input");
+inputs.forEach((el) => el.addEventListener("input", submit));
+function submit() {
+	const code = Array.from(inputs, (el) => el.value).join("");
+	if (code.length < inputs.length) {
+		return;
+	}
+	fetch("/enter_code", {
+		method: "POST",
+		body: new URLSearchParams({code: code}),
+	}).then((r) => r.json()).then((data) => {
+		if (data.ok) {
+			window.location.href = "/ssb/redirect";
+			return;
+		}
+		inputs.forEach((el) => el.value = "");
+		document.getElementById("code_error").innerHTML =
+			'<div data-qa="2fa_code_error_alert">That code didn\'t work.</div>';
+	});
+}
+</script>
+</body>
+</html>
+`))
+
+var tmRedirect = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Redirecting | Slack</title></head>
+<body>
+<a href="/client/{{.Team}}" data-qa="ssb_redirect_open_in_browser">use Slack in your browser</a>
+</body>
+</html>
+`))
+
+var tmClient = template.Must(template.New("client").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Slack</title></head>
+<body>
+<script>var boot_data = {"api_token":"{{.Token}}"};</script>
+<script>
+const form = new FormData();
+form.append("token", boot_data.api_token);
+fetch("/api/api.features", {method: "POST", body: form});
+</script>
+</body>
+</html>
+`))
+
+var tmExpired = template.Must(template.New("expired").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Link expired | Slack</title></head>
+<body>
+<p>This link has expired.</p>
+</body>
+</html>
+`))
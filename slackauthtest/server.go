@@ -0,0 +1,301 @@
+// Package slackauthtest provides a local imitation of the Slack workspace
+// login flow for end-to-end tests, in the spirit of net/http/httptest.
+//
+// The server serves the password sign in page with the same elements that
+// slackauth looks for, the error states, the challenge code page, the "open
+// in browser" redirect page, the web client page that sends the token to
+// the api.features endpoint, and the magic login links.
+//
+// To target the server, pass [Server.URL] as the workspace to slackauth:
+//
+//	srv := slackauthtest.NewServer()
+//	defer srv.Close()
+//	cl, err := slackauth.New(srv.URL, slackauth.WithSkipWorkspaceCheck())
+package slackauthtest
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultEmail    = "user@example.com"
+	DefaultPassword = "correct-horse-battery"
+	DefaultToken    = "xoxc-1111111111-2222222222-3333333333-abcdef0123456789"
+	DefaultCookie   = "xoxd-fake%2Fsession%3D"
+	TeamID          = "T0123ABCD"
+)
+
+const (
+	cookieD       = "d" // session cookie name
+	msgBadCreds   = "Sorry, you entered an incorrect email address or password."
+	linkPrefix    = "z-app-" // magic login link path prefix
+	expiredMarker = "expired"
+)
+
+// Server is the fake Slack workspace.
+type Server struct {
+	*httptest.Server
+
+	opts options
+
+	mu     sync.Mutex
+	tokens []string // tokens received by the api.features endpoint
+}
+
+// Option configures the server.
+type Option func(*options)
+
+type options struct {
+	email      string
+	password   string
+	token      string
+	cookie     string
+	code       int    // challenge code, 0 disables the challenge
+	emailFirst bool   // start on the email sign in page
+	loginError string // generic login error
+}
+
+// WithCredentials sets the valid email and password.
+func WithCredentials(email, password string) Option {
+	return func(o *options) {
+		o.email = email
+		o.password = password
+	}
+}
+
+// WithToken sets the token that the web client sends to the api.features
+// endpoint.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithSessionCookie sets the value of the "d" session cookie.
+func WithSessionCookie(value string) Option {
+	return func(o *options) {
+		o.cookie = value
+	}
+}
+
+// WithChallengeCode makes the server challenge the browser with the
+// 6-digit confirmation code after the password sign in, as Slack does for
+// the unknown browsers.
+func WithChallengeCode(code int) Option {
+	return func(o *options) {
+		o.code = code
+	}
+}
+
+// WithEmailLogin makes the sign in page offer the email login first, the
+// password form is shown after clicking the "sign in with password" link.
+func WithEmailLogin() Option {
+	return func(o *options) {
+		o.emailFirst = true
+	}
+}
+
+// WithLoginError makes the server reject any sign in attempt with the
+// generic error message, i.e. "Your account has been locked".
+func WithLoginError(msg string) Option {
+	return func(o *options) {
+		o.loginError = msg
+	}
+}
+
+// NewServer starts and returns the new fake Slack workspace server.  The
+// caller should call Close when finished, to shut it down.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		opts: options{
+			email:    DefaultEmail,
+			password: DefaultPassword,
+			token:    DefaultToken,
+			cookie:   DefaultCookie,
+		},
+	}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	s.Server = httptest.NewServer(s.handler())
+	return s
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleClient)
+	mux.HandleFunc("GET /client/{team}", s.handleClient)
+	mux.HandleFunc("GET /sign_in_with_password", s.handleSignInPage)
+	mux.HandleFunc("POST /sign_in_with_password", s.handleSignIn)
+	mux.HandleFunc("GET /enter_code", s.handleEnterCodePage)
+	mux.HandleFunc("POST /enter_code", s.handleEnterCode)
+	mux.HandleFunc("GET /ssb/redirect", s.handleRedirect)
+	mux.HandleFunc("POST /api/api.features", s.handleFeatures)
+	mux.HandleFunc("GET /{link...}", s.handleMagicLink)
+	return mux
+}
+
+// Workspace returns the workspace URL, with the trailing slash.
+func (s *Server) Workspace() string {
+	return s.URL + "/"
+}
+
+// LoginLink returns the magic login link, as sent by email or encoded in
+// the QR code.
+func (s *Server) LoginLink() string {
+	return s.URL + "/" + linkPrefix + TeamID + "-4567890123/redeem"
+}
+
+// ExpiredLink returns the magic login link that has expired.
+func (s *Server) ExpiredLink() string {
+	return s.URL + "/" + linkPrefix + TeamID + "-" + expiredMarker
+}
+
+// SessionCookie returns the session cookie, that makes the server treat the
+// browser as signed in.
+func (s *Server) SessionCookie() *http.Cookie {
+	u, _ := url.Parse(s.URL)
+	return &http.Cookie{
+		Name:   cookieD,
+		Value:  s.opts.cookie,
+		Domain: u.Hostname(),
+		Path:   "/",
+	}
+}
+
+// Tokens returns the tokens received by the api.features endpoint.
+func (s *Server) Tokens() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.tokens...)
+}
+
+// signedIn returns true if the request has the valid session cookie.
+func (s *Server) signedIn(r *http.Request) bool {
+	c, err := r.Cookie(cookieD)
+	return err == nil && c.Value == s.opts.cookie
+}
+
+// startSession sets the session cookie.
+func (s *Server) startSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieD,
+		Value:    s.opts.cookie,
+		Path:     "/",
+		HttpOnly: true,
+	})
+}
+
+func (s *Server) handleSignInPage(w http.ResponseWriter, r *http.Request) {
+	if s.signedIn(r) {
+		http.Redirect(w, r, "/ssb/redirect", http.StatusFound)
+		return
+	}
+	s.renderSignIn(w, r.URL.Query().Has("with_password"), "", false)
+}
+
+func (s *Server) handleSignIn(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch {
+	case s.opts.loginError != "":
+		s.renderSignIn(w, true, s.opts.loginError, false)
+	case r.PostForm.Get("email") != s.opts.email || r.PostForm.Get("password") != s.opts.password:
+		s.renderSignIn(w, true, msgBadCreds, true)
+	case s.opts.code != 0:
+		http.Redirect(w, r, "/enter_code", http.StatusFound)
+	default:
+		s.startSession(w)
+		http.Redirect(w, r, "/ssb/redirect", http.StatusFound)
+	}
+}
+
+func (s *Server) renderSignIn(w http.ResponseWriter, withPassword bool, errMsg string, badCreds bool) {
+	render(w, tmSignIn, map[string]any{
+		"Password": withPassword || !s.opts.emailFirst,
+		"Error":    errMsg,
+		"BadCreds": badCreds,
+	})
+}
+
+func (s *Server) handleEnterCodePage(w http.ResponseWriter, r *http.Request) {
+	render(w, tmEnterCode, nil)
+}
+
+func (s *Server) handleEnterCode(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	code, err := strconv.Atoi(r.PostForm.Get("code"))
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil || code != s.opts.code {
+		fmt.Fprint(w, `{"ok":false,"error":"invalid_code"}`)
+		return
+	}
+	s.startSession(w)
+	fmt.Fprint(w, `{"ok":true}`)
+}
+
+func (s *Server) handleRedirect(w http.ResponseWriter, r *http.Request) {
+	if !s.signedIn(r) {
+		http.Redirect(w, r, "/sign_in_with_password", http.StatusFound)
+		return
+	}
+	render(w, tmRedirect, map[string]any{"Team": TeamID})
+}
+
+func (s *Server) handleClient(w http.ResponseWriter, r *http.Request) {
+	if !s.signedIn(r) {
+		http.Redirect(w, r, "/sign_in_with_password", http.StatusFound)
+		return
+	}
+	render(w, tmClient, map[string]any{"Token": s.opts.token})
+}
+
+func (s *Server) handleFeatures(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token := r.FormValue("token")
+	s.mu.Lock()
+	s.tokens = append(s.tokens, token)
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if token != s.opts.token {
+		fmt.Fprint(w, `{"ok":false,"error":"invalid_auth"}`)
+		return
+	}
+	fmt.Fprint(w, `{"ok":true,"features":[]}`)
+}
+
+func (s *Server) handleMagicLink(w http.ResponseWriter, r *http.Request) {
+	link := r.PathValue("link")
+	if !strings.HasPrefix(link, linkPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+	if strings.Contains(link, expiredMarker) {
+		render(w, tmExpired, nil)
+		return
+	}
+	s.startSession(w)
+	http.Redirect(w, r, "/ssb/redirect", http.StatusFound)
+}
+
+func render(w http.ResponseWriter, tm *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tm.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,195 @@
+package slackauthtest
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// client returns the HTTP client with the cookie jar.
+func client(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	return &http.Client{Jar: jar}
+}
+
+func body(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestServer_signIn(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		email    string
+		password string
+		wantPath string
+		wantBody []string
+	}{
+		{
+			name:     "success",
+			email:    DefaultEmail,
+			password: DefaultPassword,
+			wantPath: "/ssb/redirect",
+			wantBody: []string{`data-qa="ssb_redirect_open_in_browser"`, `href="/client/` + TeamID + `"`},
+		},
+		{
+			name:     "invalid credentials",
+			email:    DefaultEmail,
+			password: "wrong",
+			wantPath: "/sign_in_with_password",
+			wantBody: []string{`data-qa-error="true"`, `id="password_error"`, msgBadCreds},
+		},
+		{
+			name:     "custom credentials",
+			opts:     []Option{WithCredentials("a@b.c", "secret")},
+			email:    "a@b.c",
+			password: "secret",
+			wantPath: "/ssb/redirect",
+		},
+		{
+			name:     "login error",
+			opts:     []Option{WithLoginError("Your account has been locked")},
+			email:    DefaultEmail,
+			password: DefaultPassword,
+			wantPath: "/sign_in_with_password",
+			wantBody: []string{`data-qa-error="true"`, "Your account has been locked"},
+		},
+		{
+			name:     "challenge",
+			opts:     []Option{WithChallengeCode(123456)},
+			email:    DefaultEmail,
+			password: DefaultPassword,
+			wantPath: "/enter_code",
+			wantBody: []string{`id="enter_code_app_root"`, `aria-label="digit 1 of 6"`, `aria-label="digit 6 of 6"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(tt.opts...)
+			defer srv.Close()
+
+			resp, err := client(t).PostForm(srv.URL+"/sign_in_with_password", url.Values{
+				"email":    {tt.email},
+				"password": {tt.password},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPath, resp.Request.URL.Path)
+			got := body(t, resp)
+			for _, want := range tt.wantBody {
+				assert.Contains(t, got, want)
+			}
+		})
+	}
+}
+
+func TestServer_signInPage(t *testing.T) {
+	t.Run("password", func(t *testing.T) {
+		srv := NewServer()
+		defer srv.Close()
+		resp, err := http.Get(srv.URL + "/sign_in_with_password")
+		require.NoError(t, err)
+		got := body(t, resp)
+		assert.Contains(t, got, `id="email"`)
+		assert.Contains(t, got, `id="password"`)
+		assert.NotContains(t, got, `data-qa-error`)
+	})
+	t.Run("email first", func(t *testing.T) {
+		srv := NewServer(WithEmailLogin())
+		defer srv.Close()
+		resp, err := http.Get(srv.URL + "/sign_in_with_password")
+		require.NoError(t, err)
+		got := body(t, resp)
+		assert.NotContains(t, got, `id="password"`)
+		assert.Contains(t, got, `data-qa="sign_in_password_link"`)
+
+		resp, err = http.Get(srv.URL + "/sign_in_with_password?with_password=1")
+		require.NoError(t, err)
+		assert.Contains(t, body(t, resp), `id="password"`)
+	})
+}
+
+func TestServer_enterCode(t *testing.T) {
+	srv := NewServer(WithChallengeCode(123456))
+	defer srv.Close()
+	hc := client(t)
+
+	resp, err := hc.PostForm(srv.URL+"/enter_code", url.Values{"code": {"654321"}})
+	require.NoError(t, err)
+	assert.Contains(t, body(t, resp), `"ok":false`)
+	assert.Empty(t, hc.Jar.Cookies(resp.Request.URL))
+
+	resp, err = hc.PostForm(srv.URL+"/enter_code", url.Values{"code": {"123456"}})
+	require.NoError(t, err)
+	assert.Contains(t, body(t, resp), `"ok":true`)
+	assert.NotEmpty(t, hc.Jar.Cookies(resp.Request.URL))
+}
+
+func TestServer_client(t *testing.T) {
+	srv := NewServer(WithToken("xoxc-42"))
+	defer srv.Close()
+
+	t.Run("signed out", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/client/" + TeamID)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, "/sign_in_with_password", resp.Request.URL.Path)
+	})
+	t.Run("signed in", func(t *testing.T) {
+		hc := client(t)
+		u, _ := url.Parse(srv.URL)
+		hc.Jar.SetCookies(u, []*http.Cookie{srv.SessionCookie()})
+		resp, err := hc.Get(srv.Workspace())
+		require.NoError(t, err)
+		got := body(t, resp)
+		assert.Contains(t, got, `"api_token":"xoxc-42"`)
+		assert.Contains(t, got, "/api/api.features")
+	})
+}
+
+func TestServer_features(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	var buf strings.Builder
+	mw := multipart.NewWriter(&buf)
+	require.NoError(t, mw.WriteField("token", DefaultToken))
+	require.NoError(t, mw.Close())
+
+	resp, err := http.Post(srv.URL+"/api/api.features", mw.FormDataContentType(), strings.NewReader(buf.String()))
+	require.NoError(t, err)
+	assert.Contains(t, body(t, resp), `"ok":true`)
+	assert.Equal(t, []string{DefaultToken}, srv.Tokens())
+}
+
+func TestServer_magicLink(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	t.Run("valid", func(t *testing.T) {
+		hc := client(t)
+		resp, err := hc.Get(srv.LoginLink())
+		require.NoError(t, err)
+		assert.Contains(t, body(t, resp), `data-qa="ssb_redirect_open_in_browser"`)
+		cookies := hc.Jar.Cookies(resp.Request.URL)
+		require.Len(t, cookies, 1)
+		assert.Equal(t, DefaultCookie, cookies[0].Value)
+	})
+	t.Run("expired", func(t *testing.T) {
+		resp, err := http.Get(srv.ExpiredLink())
+		require.NoError(t, err)
+		assert.Contains(t, body(t, resp), "<title>Link expired")
+	})
+}
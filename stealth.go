@@ -0,0 +1,89 @@
+package slackauth
+
+import (
+	"context"
+	_ "embed"
+	"math/rand/v2"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/input"
+	"github.com/go-rod/rod/lib/launcher"
+)
+
+// stealthJS contains the evasions for the headless browser detection.
+//
+//go:embed assets/stealth.js
+var stealthJS string
+
+const (
+	minKeyDelay = 40 * time.Millisecond  // minimum delay between keystrokes
+	maxKeyDelay = 180 * time.Millisecond // maximum delay between keystrokes
+)
+
+// stealthFlags removes the launcher flags that reveal the automation.
+func stealthFlags(l *launcher.Launcher, headless bool) *launcher.Launcher {
+	l = l.Delete("enable-automation").
+		Set("disable-blink-features", "AutomationControlled")
+	if headless {
+		// the new headless mode is the same browser as the headful one,
+		// the old one is a separate implementation that is easy to detect.
+		l = l.HeadlessNew(true)
+	}
+	return l
+}
+
+// applyStealth injects the evasion scripts into the page.  It must be
+// called before navigating to the target page.
+func applyStealth(pg *rod.Page) error {
+	_, err := pg.EvalOnNewDocument(stealthJS)
+	return err
+}
+
+// input fills in the text into the element.  If human typing is enabled, it
+// types the text key by key with random delays between the keystrokes,
+// otherwise it inserts the text at once.
+func (c *Client) input(el *rod.Element, text string) error {
+	if !c.opts.humanTyping {
+		return el.Input(text)
+	}
+	return typeHuman(el, text, keyDelay)
+}
+
+// typeHuman types the text into the element, calling delay before each
+// keystroke.  Printable ASCII characters are typed with the key events, the
+// rest are inserted as text.
+func typeHuman(el *rod.Element, text string, delay func() time.Duration) error {
+	if err := el.Focus(); err != nil {
+		return err
+	}
+	ctx := el.GetContext()
+	pg := el.Page()
+	for _, r := range text {
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(delay()):
+		}
+		var err error
+		if isTypeable(r) {
+			err = pg.Keyboard.Type(input.Key(r))
+		} else {
+			err = pg.InsertText(string(r))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isTypeable returns true if the rune can be typed with the key events.
+func isTypeable(r rune) bool {
+	return r >= ' ' && r <= '~'
+}
+
+// keyDelay returns the random delay between keystrokes.
+func keyDelay() time.Duration {
+	return minKeyDelay + rand.N(maxKeyDelay-minKeyDelay)
+}
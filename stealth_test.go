@@ -0,0 +1,45 @@
+package slackauth
+
+import (
+	"testing"
+
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/launcher/flags"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_stealthFlags(t *testing.T) {
+	t.Run("headless", func(t *testing.T) {
+		l := stealthFlags(launcher.New().Headless(true), true)
+		assert.False(t, l.Has("enable-automation"))
+		assert.Equal(t, "AutomationControlled", l.Get("disable-blink-features"))
+		assert.Equal(t, "new", l.Get(flags.Headless))
+	})
+	t.Run("headful", func(t *testing.T) {
+		l := stealthFlags(launcher.New().Headless(false), false)
+		assert.False(t, l.Has("enable-automation"))
+		assert.False(t, l.Has(flags.Headless))
+	})
+}
+
+func Test_keyDelay(t *testing.T) {
+	for range 1000 {
+		d := keyDelay()
+		if d < minKeyDelay || d >= maxKeyDelay {
+			t.Fatalf("keyDelay() = %v, want in [%v, %v)", d, minKeyDelay, maxKeyDelay)
+		}
+	}
+}
+
+func Test_isTypeable(t *testing.T) {
+	for _, r := range "aZ09 !~@" {
+		assert.True(t, isTypeable(r), string(r))
+	}
+	for _, r := range "\n\tü🍌" {
+		assert.False(t, isTypeable(r), string(r))
+	}
+}
+
+func Test_stealthJS(t *testing.T) {
+	assert.Contains(t, stealthJS, "webdriver")
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package slackauth
+
+import (
+	"os"
+	"strings"
+)
+
+// localTimezone returns the IANA name of the local timezone, or an empty
+// string if it can't be determined.  /etc/localtime is a symlink to the
+// zoneinfo file on most systems.
+func localTimezone() string {
+	link, err := os.Readlink("/etc/localtime")
+	if err != nil {
+		return ""
+	}
+	if _, name, ok := strings.Cut(link, "zoneinfo/"); ok {
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,14 @@
+package slackauth
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_windowsZones(t *testing.T) {
+	for win, iana := range windowsZones {
+		if _, err := time.LoadLocation(iana); err != nil {
+			t.Errorf("%s: %v", win, err)
+		}
+	}
+}
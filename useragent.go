@@ -3,6 +3,7 @@ package slackauth
 import (
 	"fmt"
 	"runtime"
+	"strings"
 
 	"github.com/go-rod/rod/lib/proto"
 )
@@ -21,8 +22,8 @@ type userAgentSetter interface {
 
 // setUserAgent sets the user agent for the page.
 func (o options) setUserAgent(page userAgentSetter) error {
-	if o.userAgent != "" {
-		if err := page.SetUserAgent(&proto.NetworkSetUserAgentOverride{UserAgent: o.userAgent}); err != nil {
+	if ovr := o.userAgentOverride(); ovr != nil {
+		if err := page.SetUserAgent(ovr); err != nil {
 			return err
 		}
 	}
@@ -30,10 +31,35 @@ func (o options) setUserAgent(page userAgentSetter) error {
 	return nil
 }
 
+// userAgentOverride returns the user agent override for the page, or nil, if
+// the user agent should not be overridden.  The user agent set with
+// [WithUserAgent] takes precedence over the fingerprint one, and the latter
+// takes precedence over the one detected from the browser.  The
+// Accept-Language set with [WithAcceptLanguage] or [WithLocale] takes
+// precedence over the fingerprint one.
+func (o options) userAgentOverride() *proto.NetworkSetUserAgentOverride {
+	var ovr proto.NetworkSetUserAgentOverride
+	switch {
+	case o.userAgent != "":
+		ovr.UserAgent = o.userAgent
+	case o.fingerprint != nil && o.fingerprint.UserAgent != "":
+		ovr = *o.fingerprint.userAgentOverride()
+	case o.uaOverride != nil:
+		ovr = *o.uaOverride
+	}
+	if ovr.UserAgent == "" {
+		return nil
+	}
+	if al := o.emulation().AcceptLanguage; al != "" {
+		ovr.AcceptLanguage = al
+	}
+	return &ovr
+}
+
 // UserAgent returns a user agent string with the given WebKit and Chrome
 // versions, and the OS.  If any of the versions are empty, the default
 // versions are used.  If the OS is empty, the OS is determined from the
-// runtime.GOOS.
+// runtime.GOOS and runtime.GOARCH.
 func UserAgent(webkitVer, chromeVer, os string) string {
 	if webkitVer == "" {
 		webkitVer = defaultWebkitVersion
@@ -42,22 +68,210 @@ func UserAgent(webkitVer, chromeVer, os string) string {
 		chromeVer = defaultChromeVersion
 	}
 	if os == "" {
-		os = userAgentOS(runtime.GOOS)
+		os = userAgentOS(runtime.GOOS, runtime.GOARCH)
 	}
 
 	return fmt.Sprintf(`Mozilla/5.0 (%[1]s) AppleWebKit/%[2]s (KHTML, like Gecko) Chrome/%[3]s Safari/%[2]s`, os, webkitVer, chromeVer)
 }
 
-func userAgentOS(goos string) string {
+// userAgentOS returns the OS part of the user agent string.  Chrome reports
+// the same values for all architectures on macOS and Windows.
+func userAgentOS(goos, goarch string) string {
 	switch goos {
 	case "darwin":
 		return "Macintosh; Intel Mac OS X 10_15_7"
-	case "linux":
-		return "X11; Linux x86_64"
 	case "windows":
 		return "Windows NT 10.0; Win64; x64"
 	default:
-		return "X11; Linux x86_64"
+		return "X11; Linux " + linuxArch(goarch)
+	}
+}
+
+// linuxArch returns the machine name, as reported by uname on Linux.
+func linuxArch(goarch string) string {
+	switch goarch {
+	case "arm64":
+		return "aarch64"
+	case "arm":
+		return "armv7l"
+	case "386":
+		return "i686"
+	default:
+		return "x86_64"
 	}
+}
 
+// detectUserAgent queries the version of the running browser and sets the user
+// agent override that matches it.  The override is applied to all pages
+// opened after this call.
+func (c *Client) detectUserAgent(b proto.Client) error {
+	if c.opts.userAgent != "" {
+		return nil
+	}
+	if c.opts.fingerprint != nil {
+		return c.updateFingerprint(b)
+	}
+	// Accept-Language can only be set together with the user agent, so we
+	// need to know the browser's one.
+	needUA := c.opts.acceptLanguage != "" || c.opts.locale != ""
+	if !c.opts.autoUA && !needUA {
+		return nil
+	}
+	ver, err := proto.BrowserGetVersion{}.Call(b)
+	if err != nil {
+		return err
+	}
+	if !c.opts.autoUA {
+		c.opts.uaOverride = &proto.NetworkSetUserAgentOverride{
+			UserAgent: strings.Replace(ver.UserAgent, "HeadlessChrome/", "Chrome/", 1),
+		}
+		return nil
+	}
+	c.opts.uaOverride = autoUserAgent(ver.Product, c.opts.uaBrowserName(ver), runtime.GOOS, runtime.GOARCH)
+	c.opts.lg.Debug("detected browser version", "product", ver.Product, "user_agent", c.opts.uaOverride.UserAgent)
+	return nil
+}
+
+// updateFingerprint sets the user agent of the fingerprint to the one of the
+// running browser, see [autoUserAgent].  The fingerprint loaded with
+// [WithFingerprintFile] is updated when its user agent is stale, and saved
+// to the file, the one set with [WithFingerprint] only when it has no user
+// agent.
+func (c *Client) updateFingerprint(b proto.Client) error {
+	fp := c.opts.fingerprint
+	if c.opts.fingerprintFile == "" && fp.UserAgent != "" {
+		return nil
+	}
+	ver, err := proto.BrowserGetVersion{}.Call(b)
+	if err != nil {
+		return err
+	}
+	ua := autoUserAgent(ver.Product, c.opts.uaBrowserName(ver), runtime.GOOS, runtime.GOARCH)
+	if !fp.staleUserAgent(ua) {
+		return nil
+	}
+	fp.UserAgent, fp.Platform, fp.UserAgentMetadata = ua.UserAgent, ua.Platform, ua.UserAgentMetadata
+	c.opts.lg.Debug("updated fingerprint user agent", "product", ver.Product, "user_agent", fp.UserAgent)
+	if c.opts.fingerprintFile == "" {
+		return nil
+	}
+	if err := fp.Save(c.opts.fingerprintFile); err != nil {
+		return fmt.Errorf("failed to save fingerprint: %w", err)
+	}
+	return nil
+}
+
+// uaBrowserName returns the name of the running browser for the user agent.
+// Edge reports itself in the version.  Other browsers are told apart by the
+// executable, that the client launched, and the browser that the client
+// attached to is reported as Chromium, as its executable is unknown.
+func (o options) uaBrowserName(ver *proto.BrowserGetVersionResult) string {
+	if strings.HasPrefix(ver.Product, "Edg/") || strings.Contains(ver.UserAgent, " Edg/") {
+		return bEdge
+	}
+	if o.isAttached() {
+		return bChromium
+	}
+	if binpath, ok := o.browserPath(); ok {
+		return browserName(binpath)
+	}
+	return bChromium // bundled browser
+}
+
+// autoUserAgent returns the user agent override for the browser product (as
+// returned by Browser.getVersion, i.e. "HeadlessChrome/130.0.6723.58") with
+// the given name, running on goos/goarch.  The user agent string follows
+// the Chrome's reduced user agent format, and the client hints metadata is
+// consistent with it.
+func autoUserAgent(product, name, goos, goarch string) *proto.NetworkSetUserAgentOverride {
+	_, fullVer, _ := strings.Cut(product, "/")
+	if fullVer == "" {
+		fullVer = defaultChromeVersion
+	}
+	major, _, _ := strings.Cut(fullVer, ".")
+
+	brands := []*proto.EmulationUserAgentBrandVersion{
+		{Brand: "Chromium", Version: major},
+		{Brand: "Not?A_Brand", Version: "99"},
+	}
+	fullBrands := []*proto.EmulationUserAgentBrandVersion{
+		{Brand: "Chromium", Version: fullVer},
+		{Brand: "Not?A_Brand", Version: "99.0.0.0"},
+	}
+	if brand := uaBrand(name); brand != "" {
+		brands = append(brands, &proto.EmulationUserAgentBrandVersion{Brand: brand, Version: major})
+		fullBrands = append(fullBrands, &proto.EmulationUserAgentBrandVersion{Brand: brand, Version: fullVer})
+	}
+
+	platform, platformVer := uaPlatform(goos)
+	arch, bitness := "x86", "64"
+	switch goarch {
+	case "arm64":
+		arch = "arm"
+	case "arm":
+		arch, bitness = "arm", "32"
+	case "386":
+		bitness = "32"
+	}
+
+	ua := UserAgent(defaultWebkitVersion, major+".0.0.0", userAgentOS(goos, goarch))
+	if name == bEdge {
+		ua += " Edg/" + major + ".0.0.0"
+	}
+
+	return &proto.NetworkSetUserAgentOverride{
+		UserAgent: ua,
+		Platform:  navigatorPlatform(goos, goarch),
+		UserAgentMetadata: &proto.EmulationUserAgentMetadata{
+			Brands:          brands,
+			FullVersionList: fullBrands,
+			FullVersion:     fullVer,
+			Platform:        platform,
+			PlatformVersion: platformVer,
+			Architecture:    arch,
+			Bitness:         bitness,
+			Model:           "",
+			Mobile:          false,
+		},
+	}
+}
+
+// uaBrand returns the brand name for the Sec-CH-UA header.  Chromium does
+// not have the additional brand.
+func uaBrand(name string) string {
+	switch name {
+	case bChrome:
+		return "Google Chrome"
+	case bEdge:
+		return "Microsoft Edge"
+	case bBrave:
+		return "Brave"
+	default:
+		return ""
+	}
+}
+
+// uaPlatform returns the platform name and version for the Sec-CH-UA-Platform
+// headers.
+func uaPlatform(goos string) (platform, version string) {
+	switch goos {
+	case "darwin":
+		return "macOS", "10.15.7"
+	case "windows":
+		return "Windows", "10.0.0"
+	default:
+		return "Linux", ""
+	}
+}
+
+// navigatorPlatform returns the value of navigator.platform.
+func navigatorPlatform(goos, goarch string) string {
+	switch goos {
+	case "darwin":
+		return "MacIntel"
+	case "windows":
+		return "Win32"
+	default:
+		return "Linux " + linuxArch(goarch)
+	}
 }
@@ -2,10 +2,15 @@ package slackauth
 
 import (
 	"errors"
+	"log/slog"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/go-rod/rod/lib/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -50,7 +55,8 @@ func TestUserAgent(t *testing.T) {
 
 func Test_userAgentOS(t *testing.T) {
 	type args struct {
-		goos string
+		goos   string
+		goarch string
 	}
 	tests := []struct {
 		name string
@@ -60,35 +66,47 @@ func Test_userAgentOS(t *testing.T) {
 		{
 			name: "darwin",
 			args: args{
-				goos: "darwin",
+				goos:   "darwin",
+				goarch: "arm64",
 			},
 			want: "Macintosh; Intel Mac OS X 10_15_7",
 		},
 		{
 			name: "linux",
 			args: args{
-				goos: "linux",
+				goos:   "linux",
+				goarch: "amd64",
 			},
 			want: "X11; Linux x86_64",
 		},
+		{
+			name: "linux arm64",
+			args: args{
+				goos:   "linux",
+				goarch: "arm64",
+			},
+			want: "X11; Linux aarch64",
+		},
 		{
 			name: "windows",
 			args: args{
-				goos: "windows",
+				goos:   "windows",
+				goarch: "arm64",
 			},
 			want: "Windows NT 10.0; Win64; x64",
 		},
 		{
 			name: "default",
 			args: args{
-				goos: "unknown",
+				goos:   "unknown",
+				goarch: "amd64",
 			},
 			want: "X11; Linux x86_64",
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := userAgentOS(tt.args.goos); got != tt.want {
+			if got := userAgentOS(tt.args.goos, tt.args.goarch); got != tt.want {
 				t.Errorf("userAgentOS() = %v, want %v", got, tt.want)
 			}
 		})
@@ -97,11 +115,13 @@ func Test_userAgentOS(t *testing.T) {
 
 func Test_options_setUserAgent(t *testing.T) {
 	type fields struct {
-		cookies   []*http.Cookie
-		userAgent string
-		codeFn    func(email string) (code int, err error)
-		debug     bool
-		lg        Logger
+		cookies     []*http.Cookie
+		userAgent   string
+		uaOverride  *proto.NetworkSetUserAgentOverride
+		fingerprint *Fingerprint
+		codeFn      func(email string) (code int, err error)
+		debug       bool
+		lg          *slog.Logger
 	}
 	tests := []struct {
 		name    string
@@ -129,6 +149,38 @@ func Test_options_setUserAgent(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"detected user agent override",
+			fields{
+				uaOverride: &proto.NetworkSetUserAgentOverride{UserAgent: "auto", Platform: "Linux x86_64"},
+			},
+			func(m *MockuserAgentSetter) {
+				m.EXPECT().SetUserAgent(&proto.NetworkSetUserAgentOverride{UserAgent: "auto", Platform: "Linux x86_64"}).Return(nil)
+			},
+			false,
+		},
+		{
+			"fingerprint takes precedence over detected",
+			fields{
+				uaOverride:  &proto.NetworkSetUserAgentOverride{UserAgent: "auto"},
+				fingerprint: &Fingerprint{UserAgent: "fp", AcceptLanguage: "en-NZ,en;q=0.9", Platform: "Win32"},
+			},
+			func(m *MockuserAgentSetter) {
+				m.EXPECT().SetUserAgent(&proto.NetworkSetUserAgentOverride{UserAgent: "fp", AcceptLanguage: "en-NZ,en;q=0.9", Platform: "Win32"}).Return(nil)
+			},
+			false,
+		},
+		{
+			"explicit user agent takes precedence",
+			fields{
+				userAgent:  "blah",
+				uaOverride: &proto.NetworkSetUserAgentOverride{UserAgent: "auto"},
+			},
+			func(m *MockuserAgentSetter) {
+				m.EXPECT().SetUserAgent(&proto.NetworkSetUserAgentOverride{UserAgent: "blah"}).Return(nil)
+			},
+			false,
+		},
 		{
 			"error setting user agent",
 			fields{
@@ -143,11 +195,13 @@ func Test_options_setUserAgent(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			o := options{
-				cookies:   tt.fields.cookies,
-				userAgent: tt.fields.userAgent,
-				codeFn:    tt.fields.codeFn,
-				debug:     tt.fields.debug,
-				lg:        tt.fields.lg,
+				cookies:     tt.fields.cookies,
+				userAgent:   tt.fields.userAgent,
+				uaOverride:  tt.fields.uaOverride,
+				fingerprint: tt.fields.fingerprint,
+				codeFn:      tt.fields.codeFn,
+				debug:       tt.fields.debug,
+				lg:          tt.fields.lg,
 			}
 			ctrl := gomock.NewController(t)
 			muas := NewMockuserAgentSetter(ctrl)
@@ -158,3 +212,73 @@ func Test_options_setUserAgent(t *testing.T) {
 		})
 	}
 }
+
+func Test_autoUserAgent(t *testing.T) {
+	t.Run("headless chrome on linux", func(t *testing.T) {
+		got := autoUserAgent("HeadlessChrome/130.0.6723.58", bChrome, "linux", "amd64")
+		assert.Equal(t, `Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36`, got.UserAgent)
+		assert.NotContains(t, got.UserAgent, "Headless")
+		assert.Equal(t, "Linux x86_64", got.Platform)
+		md := got.UserAgentMetadata
+		require.NotNil(t, md)
+		assert.Equal(t, "Linux", md.Platform)
+		assert.Equal(t, "x86", md.Architecture)
+		assert.Equal(t, "64", md.Bitness)
+		assert.Equal(t, "130.0.6723.58", md.FullVersion)
+		assert.Contains(t, md.Brands, &proto.EmulationUserAgentBrandVersion{Brand: "Google Chrome", Version: "130"})
+		assert.Contains(t, md.FullVersionList, &proto.EmulationUserAgentBrandVersion{Brand: "Google Chrome", Version: "130.0.6723.58"})
+		for _, b := range md.Brands {
+			assert.NotContains(t, b.Brand, "Headless")
+		}
+	})
+	t.Run("edge on windows arm", func(t *testing.T) {
+		got := autoUserAgent("Edg/131.0.1.2", bEdge, "windows", "arm64")
+		assert.Equal(t, `Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36 Edg/131.0.0.0`, got.UserAgent)
+		assert.Equal(t, "Win32", got.Platform)
+		assert.Equal(t, "Windows", got.UserAgentMetadata.Platform)
+		assert.Equal(t, "arm", got.UserAgentMetadata.Architecture)
+		assert.Contains(t, got.UserAgentMetadata.Brands, &proto.EmulationUserAgentBrandVersion{Brand: "Microsoft Edge", Version: "131"})
+	})
+	t.Run("chromium has no extra brand", func(t *testing.T) {
+		got := autoUserAgent("Chrome/131.0.1.2", bChromium, "darwin", "arm64")
+		assert.Len(t, got.UserAgentMetadata.Brands, 2)
+		assert.Equal(t, "macOS", got.UserAgentMetadata.Platform)
+		assert.Equal(t, "MacIntel", got.Platform)
+	})
+	t.Run("chrome on linux arm", func(t *testing.T) {
+		got := autoUserAgent("Chrome/131.0.1.2", bChrome, "linux", "arm64")
+		assert.Contains(t, got.UserAgent, "(X11; Linux aarch64)")
+		assert.Equal(t, "Linux aarch64", got.Platform)
+		assert.Equal(t, "arm", got.UserAgentMetadata.Architecture)
+		assert.NotContains(t, got.UserAgent, "Edg/")
+	})
+	t.Run("unparseable product", func(t *testing.T) {
+		got := autoUserAgent("", bChrome, "linux", "amd64")
+		assert.Equal(t, UserAgent("", "", userAgentOS("linux", "amd64")), got.UserAgent)
+	})
+}
+
+func Test_options_uaBrowserName(t *testing.T) {
+	tmp := t.TempDir()
+	edge := filepath.Join(tmp, "microsoft-edge")
+	require.NoError(t, os.WriteFile(edge, nil, 0o755))
+	chrome := &proto.BrowserGetVersionResult{Product: "Chrome/131.0.6778.86", UserAgent: "Mozilla/5.0 Chrome/131.0.0.0 Safari/537.36"}
+
+	tests := []struct {
+		name string
+		opts options
+		ver  *proto.BrowserGetVersionResult
+		want string
+	}{
+		{"attached edge", options{remotePort: 9222}, &proto.BrowserGetVersionResult{Product: "Edg/131.0.2903.70"}, bEdge},
+		{"edge user agent", options{remotePort: 9222}, &proto.BrowserGetVersionResult{UserAgent: "Mozilla/5.0 Chrome/131.0.0.0 Safari/537.36 Edg/131.0.0.0"}, bEdge},
+		{"attached, not the local one", options{remotePort: 9222, localBrowser: edge}, chrome, bChromium},
+		{"launched", options{localBrowser: edge}, chrome, bEdge},
+		{"bundled", options{useBundledBrwsr: true}, chrome, bChromium},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.opts.uaBrowserName(tt.ver))
+		})
+	}
+}
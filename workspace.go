@@ -0,0 +1,221 @@
+package slackauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// slackDomains are the Slack domains, workspaces are the subdomains of these.
+// The first one is the default.
+var slackDomains = []string{
+	"slack.com",
+	"slack-gov.com", // GovSlack
+}
+
+// nonWorkspaceHosts are the subdomains of the Slack domains that are not
+// workspaces.
+var nonWorkspaceHosts = map[string]bool{
+	"app":     true,
+	"api":     true,
+	"edgeapi": true,
+	"www":     true,
+}
+
+// teamClientURL is the web client URL, that redirects to the workspace of
+// the team ID.
+var teamClientURL = "https://app.slack.com/client/"
+
+// reTeamID matches the Slack team or enterprise ID, i.e. "T0123ABCD".
+var reTeamID = regexp.MustCompile(`^[TE][A-Z0-9]{8,12}$`)
+
+const maxDNSLabel = 63 // maximum length of the DNS label
+
+// isTeamID returns true if s looks like the Slack team ID.
+func isTeamID(s string) bool {
+	return reTeamID.MatchString(s) && strings.ContainsAny(s, "0123456789")
+}
+
+// isDNSLabel returns true if s is a valid DNS label: 1 to 63 letters, digits
+// and hyphens, not starting or ending with the hyphen.
+func isDNSLabel(s string) bool {
+	if len(s) == 0 || len(s) > maxDNSLabel || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for _, r := range s {
+		if !isDNSLabelRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDNSLabelRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') ||
+		(r >= 'A' && r <= 'Z') ||
+		(r >= '0' && r <= '9') ||
+		r == '-'
+}
+
+// isHostname returns true if s is a valid host name, i.e. all labels are
+// valid DNS labels.
+func isHostname(s string) bool {
+	for _, label := range strings.Split(s, ".") {
+		if !isDNSLabel(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// isLoopback returns true if the host is the loopback address or
+// "localhost".
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// workspaceURL returns the workspace URL for the workspace, that can be one
+// of:
+//   - the workspace name, i.e. "acme", for the "acme.slack.com" workspace;
+//   - the host name, i.e. "acme.enterprise.slack.com" or
+//     "acme.slack-gov.com";
+//   - the URL, i.e. "https://acme.slack-gov.com/".  Only the scheme and the
+//     host are used.  Plain HTTP is only allowed for the loopback addresses.
+//
+// Team IDs are not handled here, see [resolveTeam].
+func workspaceURL(workspace string) (string, error) {
+	if strings.Contains(workspace, "://") {
+		u, err := url.Parse(workspace)
+		if err != nil {
+			return "", ErrBadWorkspace{Name: workspace}
+		}
+		host := u.Hostname()
+		switch {
+		case u.Scheme == "https" && isHostname(host):
+		case u.Scheme == "http" && isLoopback(host):
+		default:
+			return "", ErrBadWorkspace{Name: workspace}
+		}
+		return u.Scheme + "://" + u.Host + "/", nil
+	}
+	if strings.Contains(workspace, ".") {
+		if !isHostname(workspace) {
+			return "", ErrBadWorkspace{Name: workspace}
+		}
+		return "https://" + workspace + "/", nil
+	}
+	if !isDNSLabel(workspace) {
+		return "", ErrBadWorkspace{Name: workspace}
+	}
+	return "https://" + workspace + "." + slackDomains[0] + "/", nil
+}
+
+// slackDomain returns the Slack domain of the host, or an empty string, if
+// the host is not on any of the Slack domains.
+func slackDomain(host string) string {
+	for _, d := range slackDomains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return d
+		}
+	}
+	return ""
+}
+
+// isWorkspaceHost returns true if host is the workspace host on one of the
+// Slack domains.
+func isWorkspaceHost(host string) bool {
+	d := slackDomain(host)
+	if d == "" || host == d {
+		return false
+	}
+	label, _, _ := strings.Cut(host, ".")
+	return !nonWorkspaceHosts[label]
+}
+
+// cookieDomain returns the domain for the cookies of the workspace URL, i.e.
+// ".slack.com" for "https://acme.enterprise.slack.com/", or the registrable
+// domain for the custom domains, i.e. ".example.co.uk" for
+// "https://chat.example.co.uk/".  For the IP addresses, single-label hosts
+// and the public suffixes, it returns the host itself.
+func cookieDomain(wspURL string) string {
+	u, err := url.Parse(wspURL)
+	if err != nil {
+		return "." + slackDomains[0]
+	}
+	host := u.Hostname()
+	if d := slackDomain(host); d != "" {
+		return "." + d
+	}
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if d, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return "." + d
+	}
+	return host
+}
+
+// wspDomain returns the cookie domain of the workspace without the leading
+// dot.
+func (c *Client) wspDomain() string {
+	return strings.TrimPrefix(cookieDomain(c.wspURL), ".")
+}
+
+// resolveTeam resolves the team ID to the workspace URL by following the
+// redirects of the web client URL, until it reaches the workspace host.  It
+// is best-effort: it relies on Slack redirecting the signed out web client
+// to the sign in page of the workspace, which is not documented and may
+// change, in which case it returns [ErrWorkspaceCheck], and the workspace
+// name or URL should be used instead.
+func resolveTeam(ctx context.Context, hc *http.Client, teamID string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	uri := teamClientURL + teamID
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return "", err
+	}
+	var found string
+	stop := errors.New("workspace found")
+	client := *hc
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if isWorkspaceHost(req.URL.Hostname()) {
+			found = req.URL.Scheme + "://" + req.URL.Host + "/"
+			return stop
+		}
+		if len(via) >= maxLinkRedirects {
+			return errors.New("too many redirects")
+		}
+		return nil
+	}
+	resp, err := client.Do(req)
+	if found != "" {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return found, nil
+	}
+	if err != nil {
+		if cerr := certError(uri, err); cerr != nil {
+			return "", cerr
+		}
+		return "", ErrWorkspaceCheck{URL: uri, Err: err}
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrWorkspaceNotFound
+	}
+	return "", ErrWorkspaceCheck{URL: uri, Err: fmt.Errorf("team %s did not redirect to a workspace, use the workspace name or URL", teamID)}
+}
@@ -0,0 +1,144 @@
+package slackauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_isDNSLabel(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want bool
+	}{
+		{"empty", "", false},
+		{"safe", "abcABC123-xyz", true},
+		{"tilde", "abc~", false},
+		{"dot", "abc.def", false},
+		{"underscore", "abc_def", false},
+		{"leading hyphen", "-abc", false},
+		{"trailing hyphen", "abc-", false},
+		{"too long", strings.Repeat("a", 64), false},
+		{"max length", strings.Repeat("a", 63), true},
+		{"unsafe", "abc!@#$%^&*()", false},
+		{"unicode", "🍌", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDNSLabel(tt.s); got != tt.want {
+				t.Errorf("isDNSLabel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_workspaceURL(t *testing.T) {
+	tests := []struct {
+		workspace string
+		want      string
+		wantErr   bool
+	}{
+		{workspace: "acme", want: "https://acme.slack.com/"},
+		{workspace: "acme.enterprise.slack.com", want: "https://acme.enterprise.slack.com/"},
+		{workspace: "acme.slack-gov.com", want: "https://acme.slack-gov.com/"},
+		{workspace: "https://acme.slack-gov.com/", want: "https://acme.slack-gov.com/"},
+		{workspace: "https://acme.enterprise.slack.com/client/T123/C456", want: "https://acme.enterprise.slack.com/"},
+		{workspace: "http://127.0.0.1:8080", want: "http://127.0.0.1:8080/"},
+		{workspace: "http://localhost:8080/", want: "http://localhost:8080/"},
+		{workspace: "http://acme.slack.com/", wantErr: true},
+		{workspace: "ftp://acme.slack.com/", wantErr: true},
+		{workspace: "https://ac~me.slack.com/", wantErr: true},
+		{workspace: "acme~", wantErr: true},
+		{workspace: "acme..slack.com", wantErr: true},
+		{workspace: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.workspace, func(t *testing.T) {
+			got, err := workspaceURL(tt.workspace)
+			if tt.wantErr {
+				var bw ErrBadWorkspace
+				assert.ErrorAs(t, err, &bw)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_isTeamID(t *testing.T) {
+	assert.True(t, isTeamID("T0123ABCD"))
+	assert.True(t, isTeamID("E01ABCDEFGH"))
+	assert.False(t, isTeamID("TEAMNAMES"), "no digits")
+	assert.False(t, isTeamID("t0123abcd"), "lower case")
+	assert.False(t, isTeamID("acme"))
+}
+
+func Test_cookieDomain(t *testing.T) {
+	tests := []struct {
+		wspURL string
+		want   string
+	}{
+		{"https://acme.slack.com/", ".slack.com"},
+		{"https://acme.enterprise.slack.com/", ".slack.com"},
+		{"https://acme.slack-gov.com/", ".slack-gov.com"},
+		{"https://chat.example.com/", ".example.com"},
+		{"https://chat.co.uk/", ".chat.co.uk"},
+		{"https://slack.acme.example.co.uk/", ".example.co.uk"},
+		{"https://co.uk/", "co.uk"},
+		{"http://127.0.0.1:8080/", "127.0.0.1"},
+		{"http://localhost:8080/", "localhost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wspURL, func(t *testing.T) {
+			assert.Equal(t, tt.want, cookieDomain(tt.wspURL))
+		})
+	}
+}
+
+func Test_isWorkspaceHost(t *testing.T) {
+	assert.True(t, isWorkspaceHost("acme.slack.com"))
+	assert.True(t, isWorkspaceHost("acme.enterprise.slack.com"))
+	assert.True(t, isWorkspaceHost("acme.slack-gov.com"))
+	assert.False(t, isWorkspaceHost("app.slack.com"))
+	assert.False(t, isWorkspaceHost("slack.com"))
+	assert.False(t, isWorkspaceHost("acme.example.com"))
+}
+
+func Test_resolveTeam(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/client/T0123ABCD":
+			http.Redirect(w, r, "/signin", http.StatusFound)
+		case "/signin":
+			http.Redirect(w, r, "https://acme.slack.com/?redir=%2Fclient%2FT0123ABCD", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	old := teamClientURL
+	teamClientURL = srv.URL + "/client/"
+	t.Cleanup(func() { teamClientURL = old })
+
+	got, err := resolveTeam(context.Background(), srv.Client(), "T0123ABCD")
+	require.NoError(t, err)
+	assert.Equal(t, "https://acme.slack.com/", got)
+
+	_, err = resolveTeam(context.Background(), srv.Client(), "T9999ZZZZ")
+	assert.ErrorIs(t, err, ErrWorkspaceNotFound)
+}
+
+func TestWithNoConsentPrompt(t *testing.T) {
+	c, err := New("https://acme.slack-gov.com/", WithSkipWorkspaceCheck(), WithNoConsentPrompt())
+	require.NoError(t, err)
+	require.Len(t, c.opts.cookies, 1)
+	assert.Equal(t, ".slack-gov.com", c.opts.cookies[0].Domain)
+	assert.Equal(t, "OptanonAlertBoxClosed", c.opts.cookies[0].Name)
+}